@@ -1,28 +1,39 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/config"
 	"github.com/aboyadzhiev/snip/server/internal/handler"
+	"github.com/aboyadzhiev/snip/server/internal/metrics"
 	"github.com/aboyadzhiev/snip/server/internal/service"
 	"github.com/aboyadzhiev/snip/server/internal/store"
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
+	"github.com/aboyadzhiev/snip/server/internal/tracing"
 	"github.com/aboyadzhiev/snip/server/internal/urlhaus"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/httprate"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/valkey-io/valkey-go"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -54,46 +65,129 @@ func run(
 		flags.PrintDefaults()
 	}
 
+	configFlags := config.RegisterFlags(flags)
 	var (
-		addr = flags.String("addr", ":8081", "A TCP address to listen on e.g. 127.0.0.1:8081")
+		printConfig    = flags.Bool("print-config", false, "Print the effective configuration with the secrets redacted and exit")
+		createAPIKey   = flags.String("create-api-key", "", "Issue new API key for the given owner, print it and exit")
+		apiKeyName     = flags.String("api-key-name", "default", "A name describing the API key issued by -create-api-key")
+		exportSnapshot = flags.String("export-guardian-snapshot", "", "Export the guardian's malicious URLs into the given file and exit")
 	)
 
 	if err := flags.Parse(args[1:]); err != nil {
 		return err
 	}
 
-	db, err := initDB(ctx, getenv)
+	cfg, err := config.Load(configFlags, getenv)
+	if err != nil {
+		return err
+	}
+	if *printConfig {
+		return config.Print(stdout, cfg)
+	}
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	shutdownTracing, err := tracing.Init(ctx, tracing.Config{Exporter: cfg.Tracing.Exporter, File: cfg.Tracing.File}, stdout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The spans are flushed with fresh context, as the given one is done by now.
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error flushing spans: %s\n", err)
+		}
+	}()
+
+	db, replicas, err := initDB(ctx, cfg.Postgres)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
+	var replicaStores []store.ShortenedURL
+	for _, replica := range replicas {
+		defer replica.Close()
+		replicaStores = append(replicaStores, store.NewShortenedURL(replica))
+	}
+
+	authenticator := service.NewAPIKeyAuthenticator(store.NewAPIKey(db))
+	if *createAPIKey != "" {
+		key, err := authenticator.Create(ctx, strings.TrimSpace(*createAPIKey), *apiKeyName)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, key)
+		return nil
+	}
 
-	valkeyClient, err := initValkeyClient(ctx, getenv)
+	valkeyClient, err := initValkeyClient(ctx, cfg.Valkey)
 	if err != nil {
 		return err
 	}
 	defer valkeyClient.Close()
 
-	logger := slog.New(slog.NewJSONHandler(stdout, nil))
+	logger := slog.New(tracing.NewLogHandler(slog.NewJSONHandler(stdout, nil)))
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.NewPoolCollector(db),
+	)
+	valkeyClient = metrics.NewValkeyClient(tracing.NewValkeyClient(valkeyClient), registry)
 
 	validate := initValidator()
 
-	guardian := initURLGuardian(getenv, valkeyClient, logger)
+	overrides := service.NewGuardianOverrides(store.NewGuardianOverride(db), cfg.Guardian.OverridesReloadInterval, logger)
+
+	guardian, err := initURLGuardian(cfg.Guardian, cfg.Server.Hostname, valkeyClient, overrides, logger)
+	if err != nil {
+		return err
+	}
+
+	if *exportSnapshot != "" {
+		return exportGuardianSnapshot(ctx, guardian, *exportSnapshot, logger)
+	}
+	registry.MustRegister(metrics.NewGuardianCollector(guardian))
+
+	// The cache keeps the found URLs and the misses for their own TTLs and is loaded from the primary only, so that it
+	// never holds rows of lagging replica. The read replicas serve the redirects only while Valkey is unavailable.
+	primaryStore := store.NewShortenedURL(db)
+	shortenedURLStore := store.NewCachedShortenedURL(primaryStore, valkeyClient, cfg.Shortener.CacheTTL, cfg.Shortener.CacheMissTTL)
+	replicatedStore := store.NewReplicatedShortenedURL(primaryStore, replicaStores)
+	redirectStore := store.NewCachedShortenedURLWithFallback(primaryStore, replicatedStore, valkeyClient, cfg.Shortener.CacheTTL, cfg.Shortener.CacheMissTTL)
 
-	hostname := strings.TrimSpace(getenv("SNIP_HOSTNAME"))
-	shortener, err := initURLShortener(logger, hostname, valkeyClient, db, guardian)
+	shortener, err := initURLShortener(ctx, cfg.Shortener, logger, cfg.Server.Hostname, valkeyClient, db, shortenedURLStore, redirectStore, guardian)
 	if err != nil {
 		return err
 	}
+	shortener = metrics.NewURLShortener(tracing.NewURLShortener(shortener), registry)
+
+	reaper := service.NewURLReaper(shortenedURLStore, cfg.Jobs.ReaperRetention, logger)
+
+	rescanner := service.NewURLRescanner(shortenedURLStore, guardian, cfg.Guardian.RescanBatchSize, logger)
+
+	tracker, err := initClickTracker(cfg.Jobs, valkeyClient, db, shortenedURLStore, logger)
+	if err != nil {
+		return err
+	}
+
+	readiness := initReadiness(cfg.Guardian, db, replicas, valkeyClient, guardian)
 
-	srv := NewServer(logger, validate, shortener)
+	authenticate := handler.Authenticate(authenticator, cfg.Server.AllowAnonymous)
+
+	requireAdmin := handler.RequireAdmin(cfg.Server.AdminOwners)
+
+	srv := NewServer(cfg.Server, logger, validate, shortener, tracker, guardian, overrides, authenticate, requireAdmin, metrics.Instrument(registry))
 
 	httpServer := &http.Server{
-		Addr:         *addr,
+		Addr:         cfg.Server.Addr,
 		Handler:      srv,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
 	go func() {
@@ -104,13 +198,36 @@ func run(
 		logger.Info("Stopped serving new connections")
 	}()
 
+	// The metrics and the probes are served on separate listener, so that they aren't exposed along with the public API
+	// nor rate limited.
+	var adminServer *http.Server
+	if cfg.Server.AdminAddr != "" {
+		adminServer = &http.Server{
+			Addr:         cfg.Server.AdminAddr,
+			Handler:      NewAdminServer(registry, readiness),
+			IdleTimeout:  cfg.Server.IdleTimeout,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+		go func() {
+			logger.Info(fmt.Sprintf("Admin listening and serving on %s", adminServer.Addr))
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				_, _ = fmt.Fprintf(stderr, "Error listening and serving admin: %s\n", err)
+			}
+		}()
+	}
+
 	var wg sync.WaitGroup
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		<-ctx.Done()
-		shutdownCtx, shutdownCtxRelease := context.WithTimeout(context.Background(), 15*time.Second)
+		// The replica reports itself unready first and keeps serving until the orchestrator moves the traffic away.
+		readiness.Drain()
+		logger.Info(fmt.Sprintf("Draining for %v before shutting down", cfg.Server.DrainDelay))
+		time.Sleep(cfg.Server.DrainDelay)
+		shutdownCtx, shutdownCtxRelease := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 		defer shutdownCtxRelease()
 		if err := httpServer.Shutdown(shutdownCtx); err != nil {
 			_, _ = fmt.Fprintf(stderr, "Error shutting down: %s\n", err)
@@ -118,27 +235,114 @@ func run(
 				_, _ = fmt.Fprintf(stderr, "Error closing: %s\n", err)
 			}
 		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(shutdownCtx); err != nil {
+				_, _ = fmt.Fprintf(stderr, "Error shutting down admin: %s\n", err)
+			}
+		}
 		logger.Info("Shutdown completed")
 	}()
 
+	// The guardian's refresh and the reaper run only on the replica elected as their leader.
+	holder, err := leaseHolder()
+	if err != nil {
+		return err
+	}
+	guardianLease := store.NewLease(valkeyClient, "guardian", holder, cfg.Jobs.LeaseTTL)
+	guardianElection := service.NewLeaderElection("guardian", guardianLease, cfg.Jobs.LeaseRenewInterval, logger)
+	reaperLease := store.NewLease(valkeyClient, "reaper", holder, cfg.Jobs.LeaseTTL)
+	reaperElection := service.NewLeaderElection("reaper", reaperLease, cfg.Jobs.LeaseRenewInterval, logger)
+
 	wg.Add(1)
-	ticker := time.NewTicker(5 * time.Minute)
 	go func() {
 		defer wg.Done()
-		logger.Info("Initializing guardian's database")
-		if err := guardian.UpdateDB(ctx); err != nil {
-			logger.Error("Error while initializing guardian's database", "err", err)
-		}
+		guardianElection.Run(ctx, func(ctx context.Context, token int64) {
+			// Each provider is refreshed on its own schedule, the ticker only determines how often they are checked.
+			ticker := time.NewTicker(cfg.Guardian.UpdateInterval)
+			defer ticker.Stop()
+			// updateDB reports whether this replica is still the leader.
+			updateDB := func() bool {
+				refreshed, err := guardian.UpdateDB(ctx, token)
+				if err != nil {
+					logger.Error("Error while updating guardian's database", "err", err)
+				}
+				if errors.Is(err, service.ErrStaleFencingToken) {
+					// Another replica has taken over the leadership.
+					return false
+				}
+				// The stored URLs are re-checked only when some of the feeds has actually been refreshed, including
+				// the initial load, so that the URLs shortened before it are checked as well.
+				if refreshed > 0 {
+					logger.Info("Rescanning shortened URLs")
+					if err := rescanner.Rescan(ctx); err != nil {
+						logger.Error("Error while rescanning shortened URLs", "err", err)
+					}
+				}
+
+				return true
+			}
+			logger.Info("Initializing guardian's database")
+			if !updateDB() {
+				return
+			}
+			for {
+				select {
+				case <-ctx.Done():
+					logger.Info("The guardian's update database ticker has been stopped. ...")
+					return
+				case <-ticker.C:
+					logger.Info("Updating guardian's database")
+					if !updateDB() {
+						return
+					}
+				}
+			}
+		})
+		logger.Info("The guardian's leader election has been stopped. ...")
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reaperElection.Run(ctx, func(ctx context.Context, _ int64) {
+			reaperTicker := time.NewTicker(cfg.Jobs.ReaperInterval)
+			defer reaperTicker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					logger.Info("The reaper's ticker has been stopped. ...")
+					return
+				case <-reaperTicker.C:
+					logger.Info("Archiving expired URLs")
+					if err := reaper.Reap(ctx); err != nil {
+						logger.Error("Error while archiving expired URLs", "err", err)
+					}
+				}
+			}
+		})
+		logger.Info("The reaper's leader election has been stopped. ...")
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tracker.Run(ctx)
+		logger.Info("The click tracker has been stopped. ...")
+	}()
+
+	wg.Add(1)
+	clicksTicker := time.NewTicker(cfg.Jobs.ClicksFlushInterval)
+	go func() {
+		defer wg.Done()
 		for {
 			select {
 			case <-ctx.Done():
-				ticker.Stop()
-				logger.Info("The guardian's update database ticker has been stopped. ...")
+				clicksTicker.Stop()
+				logger.Info("The click events flush ticker has been stopped. ...")
 				return
-			case <-ticker.C:
-				logger.Info("Updating guardian's database")
-				if err := guardian.UpdateDB(ctx); err != nil {
-					logger.Error("Error while updating guardian's database", "err", err)
+			case <-clicksTicker.C:
+				if err := tracker.Flush(ctx); err != nil {
+					logger.Error("Error while flushing click events", "err", err)
 				}
 			}
 		}
@@ -149,33 +353,91 @@ func run(
 	return nil
 }
 
-func NewServer(logger *slog.Logger, validate *validator.Validate, shortener service.URLShortener) http.Handler {
+func NewServer(
+	cfg config.Server,
+	logger *slog.Logger,
+	validate *validator.Validate,
+	shortener service.URLShortener,
+	tracker service.ClickTracker,
+	guardian service.URLGuardian,
+	overrides service.GuardianOverrides,
+	authenticate func(http.Handler) http.Handler,
+	requireAdmin func(http.Handler) http.Handler,
+	instrument func(http.Handler) http.Handler,
+) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
+	r.Use(tracing.Middleware)
 	r.Use(middleware.RealIP)
+	r.Use(instrument)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(httprate.LimitByRealIP(30, 1*time.Minute))
-	// Limit the max request body size to 1MB
-	r.Use(middleware.RequestSize(1_048_576))
+	r.Use(httprate.LimitByRealIP(cfg.RateLimit, cfg.RateLimitWindow))
 
-	addRoutes(r, logger, validate, shortener)
+	addRoutes(r, logger, validate, cfg.MaxBodyBytes, shortener, tracker, guardian, overrides, authenticate, requireAdmin)
 
 	var httpHandler http.Handler = r
 
 	return httpHandler
 }
 
-func addRoutes(r *chi.Mux, _ *slog.Logger, validate *validator.Validate, shortener service.URLShortener) {
+// NewAdminServer serves the operational endpoints, which must not be reachable from the public network.
+func NewAdminServer(registry *prometheus.Registry, readiness service.Readiness) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Recoverer)
+	r.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	r.Get("/livez", handler.Livez())
+	r.Get("/readyz", handler.Readyz(readiness))
+
+	return r
+}
+
+func addRoutes(
+	r *chi.Mux,
+	_ *slog.Logger,
+	validate *validator.Validate,
+	maxBodyBytes int64,
+	shortener service.URLShortener,
+	tracker service.ClickTracker,
+	guardian service.URLGuardian,
+	overrides service.GuardianOverrides,
+	authenticate func(http.Handler) http.Handler,
+	requireAdmin func(http.Handler) http.Handler,
+) {
+	// The batch has its own body limit, the rest of the routes share the configured one.
+	limitBody := middleware.RequestSize(maxBodyBytes)
+
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/healthz", handler.Healthz())
-		r.Post("/shortened-url", handler.ShortenURL(shortener, validate))
+		r.Get("/healthz/guardian", handler.GuardianHealth(guardian))
+		r.With(middleware.RequestSize(handler.MaxBatchBodyBytes), authenticate).
+			Post("/shortened-url:batch", handler.ShortenURLBatch(shortener, validate))
+		r.Route("/shortened-url", func(r chi.Router) {
+			r.Use(limitBody, authenticate)
+			r.Post("/", handler.ShortenURL(shortener, validate))
+			r.Get("/{slug}/stats", handler.ClickStats(tracker))
+			r.Group(func(r chi.Router) {
+				r.Use(handler.RequireOwner)
+				r.Get("/", handler.ListShortenedURLs(shortener))
+				r.Get("/{slug}", handler.GetShortenedURL(shortener))
+				r.Patch("/{slug}", handler.UpdateShortenedURL(shortener, validate))
+				r.Delete("/{slug}", handler.DeleteShortenedURL(shortener))
+			})
+		})
+		r.Route("/admin/guardian", func(r chi.Router) {
+			r.Use(limitBody, authenticate, handler.RequireOwner, requireAdmin)
+			r.Get("/overrides", handler.ListGuardianOverrides(overrides))
+			r.Post("/overrides", handler.CreateGuardianOverride(overrides, validate))
+			r.Delete("/overrides/{id}", handler.DeleteGuardianOverride(overrides))
+			r.Get("/audit", handler.ListGuardianOverrideAudit(overrides))
+		})
 	})
 
 	r.Route("/{slug}", func(r chi.Router) {
-		r.Use(middleware.NoCache)
-		r.Get("/", handler.Resolve(shortener))
+		r.Use(limitBody, middleware.NoCache)
+		r.Get("/", handler.Resolve(shortener, tracker))
 	})
 
 	r.Handle("/", http.NotFoundHandler())
@@ -194,65 +456,282 @@ func initValidator() *validator.Validate {
 	return validate
 }
 
-func initURLShortener(_ *slog.Logger, hostname string, valkeyClient valkey.Client, db *pgxpool.Pool, guardian service.URLGuardian) (service.URLShortener, error) {
-	sequence := store.NewShortenedURLSequence(valkeyClient)
-	shortenedURLStore := store.NewShortenedURL(db)
-	shortener := service.NewURLShortener(hostname, sequence, shortenedURLStore, guardian)
+func initURLShortener(
+	ctx context.Context,
+	cfg config.Shortener,
+	logger *slog.Logger,
+	hostname string,
+	valkeyClient valkey.Client,
+	db *pgxpool.Pool,
+	shortenedURLStore store.ShortenedURL,
+	redirectStore store.ShortenedURL,
+	guardian service.URLGuardian,
+) (service.URLShortener, error) {
+	sequence, err := initShortenedURLSequence(ctx, cfg, logger, valkeyClient, db, shortenedURLStore)
+	if err != nil {
+		return nil, err
+	}
+
+	// The slugs are obfuscated only when the secret is configured, otherwise they are plain base62 encoded IDs.
+	obfuscator := service.NewIDObfuscator([]byte(cfg.SlugSecret))
+
+	// Private destinations are refused regardless of the policy.
+	policy := service.NewDestinationPolicy(service.DestinationPolicyConfig{
+		AllowedDomains: cfg.AllowedDomains,
+		DeniedDomains:  cfg.DeniedDomains,
+		AllowedPorts:   cfg.AllowedPorts,
+		DeniedPorts:    cfg.DeniedPorts,
+	})
+
+	shortener := service.NewURLShortener(hostname, sequence, shortenedURLStore, redirectStore, guardian, policy, obfuscator)
 
 	return shortener, nil
 }
 
-func initDB(ctx context.Context, getenv func(string) string) (*pgxpool.Pool, error) {
-	user := strings.TrimSpace(getenv("POSTGRES_USER"))
-	password, err := readSecretFile(getenv("POSTGRES_PASSWORD_FILE"))
+// initShortenedURLSequence creates the configured sequence, either "valkey" or "postgres", and reconciles it with the
+// IDs already saved in the database.
+func initShortenedURLSequence(
+	ctx context.Context,
+	cfg config.Shortener,
+	logger *slog.Logger,
+	valkeyClient valkey.Client,
+	db *pgxpool.Pool,
+	shortenedURLStore store.ShortenedURL,
+) (store.ShortenedURLSequence, error) {
+	var sequence store.ShortenedURLSequence
+	switch cfg.Sequence {
+	case "postgres":
+		sequence = store.NewShortenedURLSequencePG(db)
+	default:
+		sequence = store.NewShortenedURLSequence(valkeyClient, cfg.SequenceBlockSize)
+	}
+
+	maxId, err := shortenedURLStore.MaxId(ctx)
 	if err != nil {
 		return nil, err
 	}
-	hostname := strings.TrimSpace(getenv("POSTGRES_HOST"))
-	database := strings.TrimSpace(getenv("POSTGRES_DB"))
+	if err = sequence.Reconcile(ctx, maxId); err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("The shortened URL sequence has been reconciled with max ID %d", maxId))
+
+	return sequence, nil
+}
+
+// exportGuardianSnapshot writes the snapshot into temporary file first, so that file feeds never read it half-written.
+func exportGuardianSnapshot(ctx context.Context, guardian service.URLGuardian, file string, logger *slog.Logger) error {
+	entries, err := guardian.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	file = filepath.Clean(file)
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = threatfeed.WriteSnapshot(tmp, entries); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Exported %d entries of the guardian into %s", len(entries), file))
+
+	return nil
+}
+
+// leaseHolder identifies the replica among the holders of the leases.
+func leaseHolder() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid()), nil
+}
+
+func initClickTracker(cfg config.Jobs, valkeyClient valkey.Client, db *pgxpool.Pool, shortenedURLStore store.ShortenedURL, logger *slog.Logger) (service.ClickTracker, error) {
+	// The hostname identifies the replica within the click events consumer group.
+	consumer, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	// The events of the replicas which are gone are claimed once they have missed several flushes.
+	stream := store.NewClickEventStream(valkeyClient, max(10*cfg.ClicksFlushInterval, time.Minute))
+	clicks := store.NewURLClick(db)
+
+	return service.NewClickTracker(consumer, stream, clicks, shortenedURLStore, logger), nil
+}
+
+// statementCacheModes maps the statement cache modes to the query exec modes of pgx. Disabled cache still uses the
+// extended protocol but never relies on statements prepared by earlier transactions, which PgBouncer in transaction
+// mode may have run on other server connection.
+var statementCacheModes = map[string]pgx.QueryExecMode{
+	"prepare":  pgx.QueryExecModeCacheStatement,
+	"describe": pgx.QueryExecModeCacheDescribe,
+	"disabled": pgx.QueryExecModeDescribeExec,
+}
+
+// initDB connects the pool of the primary along with the pools of the read replicas, which share its settings.
+func initDB(ctx context.Context, cfg config.Postgres) (*pgxpool.Pool, []*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, nil, err
+	}
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	poolConfig.MinConns = int32(cfg.MinConns)
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	poolConfig.ConnConfig.DefaultQueryExecMode = statementCacheModes[cfg.StatementCacheMode]
+	poolConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
 
-	url := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", user, password, hostname, database)
-	db, err := pgxpool.New(ctx, url)
+	db, err := connectDB(ctx, poolConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var replicas []*pgxpool.Pool
+	for _, replicaHost := range cfg.ReplicaHosts {
+		replica, err := connectReplica(ctx, poolConfig, replicaHost)
+		if err != nil {
+			closeDBs(db, replicas)
+			return nil, nil, fmt.Errorf("connecting postgres replica %s: %w", replicaHost, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	return db, replicas, nil
+}
+
+func connectDB(ctx context.Context, poolConfig *pgxpool.Config) (*pgxpool.Pool, error) {
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 	err = db.Ping(ctx)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func initValkeyClient(_ context.Context, getenv func(string) string) (valkey.Client, error) {
-	valkeyHosts := strings.Split(getenv("VALKEY_HOSTS"), ",")
-	valkeyClient, err := valkey.NewClient(valkey.ClientOption{InitAddress: valkeyHosts})
+// connectReplica connects the replica with the settings of the primary, only its host and port differ. The port of
+// the primary is used unless the replica host has its own.
+func connectReplica(ctx context.Context, poolConfig *pgxpool.Config, replicaHost string) (*pgxpool.Pool, error) {
+	replicaConfig := poolConfig.Copy()
+	connConfig := replicaConfig.ConnConfig
+	host, port, err := net.SplitHostPort(replicaHost)
 	if err != nil {
-		return nil, err
+		host, port = replicaHost, strconv.Itoa(int(connConfig.Port))
+	}
+	replicaPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", port)
 	}
 
-	return valkeyClient, nil
+	// The fallbacks of other hosts of the primary's DSN would connect the replica's pool to the primary, only the
+	// fallbacks differing in TLS e.g. of sslmode=prefer are kept.
+	var fallbacks []*pgconn.FallbackConfig
+	for _, fallback := range connConfig.Fallbacks {
+		if fallback.Host == connConfig.Host && fallback.Port == connConfig.Port {
+			fallback.Host, fallback.Port = host, uint16(replicaPort)
+			verifyServerName(fallback.TLSConfig, host)
+			fallbacks = append(fallbacks, fallback)
+		}
+	}
+	connConfig.Host, connConfig.Port, connConfig.Fallbacks = host, uint16(replicaPort), fallbacks
+	verifyServerName(connConfig.TLSConfig, host)
+
+	return connectDB(ctx, replicaConfig)
 }
 
-func initURLGuardian(getenv func(string) string, valkeyClient valkey.Client, logger *slog.Logger) service.URLGuardian {
-	timeout := 5 * time.Second
+// verifyServerName makes verify-full check the certificate against the replica's host instead of the primary's.
+func verifyServerName(tlsConfig *tls.Config, host string) {
+	if tlsConfig != nil && tlsConfig.ServerName != "" {
+		tlsConfig.ServerName = host
+	}
+}
 
-	httpClient := &http.Client{Timeout: timeout}
+func closeDBs(db *pgxpool.Pool, replicas []*pgxpool.Pool) {
+	db.Close()
+	for _, replica := range replicas {
+		replica.Close()
+	}
+}
 
-	urlhausClient := urlhaus.NewClient(
-		strings.TrimSpace(getenv("URLHAUS_API_ENDPOINT")),
-		httpClient,
-		logger,
-	)
+// initReadiness checks Postgres and its read replicas, Valkey and whether the guardian's feeds have been refreshed
+// within the ready max age. The replicas only degrade the replica, as the primary answers in place of them.
+func initReadiness(cfg config.Guardian, db *pgxpool.Pool, replicas []*pgxpool.Pool, valkeyClient valkey.Client, guardian service.URLGuardian) service.Readiness {
+	checks := []service.ReadinessCheck{
+		{Name: "postgres", Check: db.Ping},
+		{Name: "valkey", Check: func(ctx context.Context) error {
+			return valkeyClient.Do(ctx, valkeyClient.B().Ping().Build()).Error()
+		}},
+		service.GuardianFreshnessCheck(guardian, cfg.ReadyMaxAge),
+	}
+	for _, replica := range replicas {
+		connConfig := replica.Config().ConnConfig
+		checks = append(checks, service.ReadinessCheck{
+			Name:       fmt.Sprintf("postgres replica %s:%d", connConfig.Host, connConfig.Port),
+			Check:      replica.Ping,
+			Degradable: true,
+		})
+	}
 
-	return service.NewURLGuardian(urlhausClient, valkeyClient, logger)
+	return service.NewReadiness(checks, 2*time.Second)
 }
 
-func readSecretFile(file string) (string, error) {
-	secret, err := os.ReadFile(filepath.Clean(file))
+func initValkeyClient(_ context.Context, cfg config.Valkey) (valkey.Client, error) {
+	valkeyClient, err := valkey.NewClient(valkey.ClientOption{InitAddress: cfg.Hosts})
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return valkeyClient, nil
+}
+
+// initURLGuardian creates the guardian with the threat providers whose endpoint or file is configured. The guardian
+// follows the redirects of URLs unless disabled.
+func initURLGuardian(
+	cfg config.Guardian,
+	hostname string,
+	valkeyClient valkey.Client,
+	overrides service.GuardianOverrides,
+	logger *slog.Logger,
+) (service.URLGuardian, error) {
+	httpClient := &http.Client{Timeout: cfg.FeedTimeout, Transport: tracing.NewTransport(http.DefaultTransport)}
+
+	var providers []service.ThreatProvider
+	addProvider := func(interval time.Duration, feed threatfeed.Feed) {
+		providers = append(providers, service.ThreatProvider{Feed: feed, RefreshInterval: interval})
+		logger.Info(fmt.Sprintf("The %s threat feed is refreshed every %v", feed.Name(), interval))
+	}
+
+	if cfg.URLhausEndpoint != "" {
+		urlhausClient := urlhaus.NewClient(cfg.URLhausEndpoint, httpClient, logger)
+		addProvider(cfg.URLhausRefreshInterval, threatfeed.NewURLhausFeed(urlhausClient))
+	}
+	if cfg.OpenPhishURL != "" {
+		addProvider(cfg.OpenPhishRefreshInterval, threatfeed.NewTextFeed("openphish", cfg.OpenPhishURL, httpClient))
+	}
+	if cfg.PhishTankURL != "" {
+		addProvider(cfg.PhishTankRefreshInterval, threatfeed.NewCSVFeed("phishtank", cfg.PhishTankURL, httpClient))
+	}
+	if cfg.LocalFeedFile != "" {
+		addProvider(cfg.LocalFeedRefreshInterval, threatfeed.NewFileFeed("local", cfg.LocalFeedFile))
+	}
+
+	var resolver service.RedirectResolver
+	if cfg.ResolveRedirects {
+		resolver = service.NewRedirectResolver(hostname, cfg.MaxRedirects, cfg.RedirectTimeout)
 	}
 
-	return string(bytes.TrimSpace(secret)), nil
+	return service.NewURLGuardian(providers, valkeyClient, resolver, overrides, logger), nil
 }
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS url_map_slug_uindex;
\ No newline at end of file
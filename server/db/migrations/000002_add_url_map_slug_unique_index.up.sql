@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX IF NOT EXISTS url_map_slug_uindex
+    ON url_map (slug);
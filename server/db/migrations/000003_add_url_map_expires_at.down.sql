@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS url_map_archive;
+
+DROP INDEX IF EXISTS url_map_expires_at_index;
+
+ALTER TABLE url_map
+    DROP COLUMN IF EXISTS expires_at;
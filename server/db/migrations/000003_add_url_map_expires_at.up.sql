@@ -0,0 +1,18 @@
+ALTER TABLE url_map
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS url_map_expires_at_index
+    ON url_map (expires_at)
+    WHERE expires_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS url_map_archive
+(
+    id           BIGINT                              NOT NULL
+        CONSTRAINT url_map_archive_pk
+            PRIMARY KEY,
+    slug         TEXT                                NOT NULL,
+    original_url TEXT                                NOT NULL,
+    created_at   TIMESTAMP                           NOT NULL,
+    expires_at   TIMESTAMPTZ                         NOT NULL,
+    archived_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS url_click_referrers;
+
+DROP TABLE IF EXISTS url_clicks;
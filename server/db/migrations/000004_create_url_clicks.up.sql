@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS url_clicks
+(
+    slug   TEXT   NOT NULL,
+    day    DATE   NOT NULL,
+    clicks BIGINT NOT NULL,
+    CONSTRAINT url_clicks_pk
+        PRIMARY KEY (slug, day)
+);
+
+CREATE TABLE IF NOT EXISTS url_click_referrers
+(
+    slug     TEXT   NOT NULL,
+    day      DATE   NOT NULL,
+    referrer TEXT   NOT NULL,
+    clicks   BIGINT NOT NULL,
+    CONSTRAINT url_click_referrers_pk
+        PRIMARY KEY (slug, day, referrer)
+);
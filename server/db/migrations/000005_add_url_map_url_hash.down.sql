@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS url_map_url_hash_uindex;
+
+ALTER TABLE url_map
+    DROP COLUMN IF EXISTS url_hash;
@@ -0,0 +1,5 @@
+ALTER TABLE url_map
+    ADD COLUMN IF NOT EXISTS url_hash TEXT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS url_map_url_hash_uindex
+    ON url_map (url_hash);
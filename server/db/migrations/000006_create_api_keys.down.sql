@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS url_map_owner_id_index;
+
+ALTER TABLE url_map
+    DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         BIGSERIAL                           NOT NULL
+        CONSTRAINT api_keys_pk
+            PRIMARY KEY,
+    owner_id   TEXT                                NOT NULL,
+    name       TEXT                                NOT NULL,
+    key_hash   TEXT                                NOT NULL
+        CONSTRAINT api_keys_key_hash_uindex
+            UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMPTZ                         NULL
+);
+
+ALTER TABLE url_map
+    ADD COLUMN IF NOT EXISTS owner_id TEXT NULL;
+
+CREATE INDEX IF NOT EXISTS url_map_owner_id_index
+    ON url_map (owner_id)
+    WHERE owner_id IS NOT NULL;
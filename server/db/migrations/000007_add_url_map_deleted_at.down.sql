@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS url_map_owner_id_id_index;
+
+ALTER TABLE url_map
+    DROP COLUMN IF EXISTS deleted_at;
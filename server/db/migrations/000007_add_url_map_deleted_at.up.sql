@@ -0,0 +1,6 @@
+ALTER TABLE url_map
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS url_map_owner_id_id_index
+    ON url_map (owner_id, id DESC)
+    WHERE deleted_at IS NULL;
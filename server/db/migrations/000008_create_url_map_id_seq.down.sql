@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS url_map_id_seq;
@@ -0,0 +1,6 @@
+CREATE SEQUENCE IF NOT EXISTS url_map_id_seq
+    AS BIGINT;
+
+SELECT setval('url_map_id_seq', max_id)
+FROM (SELECT GREATEST((SELECT MAX(id) FROM url_map), (SELECT MAX(id) FROM url_map_archive)) AS max_id) AS ids
+WHERE max_id > 0;
@@ -0,0 +1,2 @@
+ALTER TABLE url_map
+    DROP COLUMN IF EXISTS quarantined_at;
@@ -0,0 +1,2 @@
+ALTER TABLE url_map
+    ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMPTZ NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS guardian_override_audit;
+DROP TABLE IF EXISTS guardian_overrides;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS guardian_overrides
+(
+    id          BIGSERIAL                              NOT NULL
+        CONSTRAINT guardian_overrides_pk
+            PRIMARY KEY,
+    kind        TEXT                                   NOT NULL
+        CONSTRAINT guardian_overrides_kind_check
+            CHECK (kind IN ('allow', 'deny')),
+    granularity TEXT                                   NOT NULL
+        CONSTRAINT guardian_overrides_granularity_check
+            CHECK (granularity IN ('exact', 'path_prefix', 'host', 'domain')),
+    pattern     TEXT                                   NOT NULL,
+    url         TEXT                                   NOT NULL,
+    reason      TEXT                                   NOT NULL,
+    author      TEXT                                   NOT NULL,
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMPTZ                            NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS guardian_overrides_kind_granularity_pattern_uindex
+    ON guardian_overrides (kind, granularity, pattern);
+
+CREATE TABLE IF NOT EXISTS guardian_override_audit
+(
+    id          BIGSERIAL                              NOT NULL
+        CONSTRAINT guardian_override_audit_pk
+            PRIMARY KEY,
+    override_id BIGINT                                 NOT NULL,
+    action      TEXT                                   NOT NULL
+        CONSTRAINT guardian_override_audit_action_check
+            CHECK (action IN ('create', 'delete')),
+    author      TEXT                                   NOT NULL,
+    override    JSONB                                  NOT NULL,
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS guardian_override_audit_override_id_index
+    ON guardian_override_audit (override_id);
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"net/url"
+	"time"
+)
+
+// Config holds all the settings of snip. Each setting is read from the YAML file given by -config or SNIP_CONFIG,
+// then from its environment variable and finally from its flag, each layer overriding the previous ones. The flag of
+// the setting is its dotted YAML path e.g. -guardian.updateInterval unless given by the flag tag. The secrets have no
+// flags.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Postgres  Postgres  `yaml:"postgres"`
+	Valkey    Valkey    `yaml:"valkey"`
+	Shortener Shortener `yaml:"shortener"`
+	Guardian  Guardian  `yaml:"guardian"`
+	Jobs      Jobs      `yaml:"jobs"`
+	Tracing   Tracing   `yaml:"tracing"`
+}
+
+type Server struct {
+	Addr            string        `yaml:"addr" flag:"addr" usage:"A TCP address to listen on e.g. 127.0.0.1:8081"`
+	AdminAddr       string        `yaml:"adminAddr" flag:"admin-addr" usage:"A TCP address of the admin listener serving /metrics and the probes, empty disables it"`
+	Hostname        string        `yaml:"hostname" env:"SNIP_HOSTNAME" usage:"The hostname used in the shortened URLs"`
+	AllowAnonymous  bool          `yaml:"allowAnonymous" env:"SNIP_ALLOW_ANONYMOUS" usage:"Whether the API accepts requests without API key"`
+	AdminOwners     []string      `yaml:"adminOwners" env:"SNIP_ADMIN_OWNERS" usage:"The API key owners allowed to administer the guardian overrides"`
+	ReadTimeout     time.Duration `yaml:"readTimeout" env:"SNIP_READ_TIMEOUT" usage:"The maximum duration of reading the request"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout" env:"SNIP_WRITE_TIMEOUT" usage:"The maximum duration of writing the response"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout" env:"SNIP_IDLE_TIMEOUT" usage:"How long idle keep-alive connections are kept open"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SNIP_SHUTDOWN_TIMEOUT" usage:"How long the in-flight requests are waited for on shutdown"`
+	DrainDelay      time.Duration `yaml:"drainDelay" env:"SNIP_SHUTDOWN_DRAIN_DELAY" usage:"How long the replica keeps serving while unready before shutting down"`
+	MaxBodyBytes    int64         `yaml:"maxBodyBytes" env:"SNIP_MAX_BODY_BYTES" usage:"The maximum size of the request body"`
+	RateLimit       int           `yaml:"rateLimit" env:"SNIP_RATE_LIMIT" usage:"The number of requests allowed per client IP within the rate limit window"`
+	RateLimitWindow time.Duration `yaml:"rateLimitWindow" env:"SNIP_RATE_LIMIT_WINDOW" usage:"The window of the rate limit"`
+}
+
+type Postgres struct {
+	URL                string        `yaml:"url" env:"DATABASE_URL" secret:"true" usage:"The URL or DSN of Postgres, overrides the individual connection settings"`
+	URLFile            string        `yaml:"urlFile" env:"DATABASE_URL_FILE" usage:"The file holding the URL or DSN of Postgres"`
+	Host               string        `yaml:"host" env:"POSTGRES_HOST" usage:"The host of Postgres"`
+	User               string        `yaml:"user" env:"POSTGRES_USER" usage:"The user of Postgres"`
+	Database           string        `yaml:"database" env:"POSTGRES_DB" usage:"The database of Postgres"`
+	Password           string        `yaml:"password" env:"POSTGRES_PASSWORD" secret:"true" usage:"The password of the Postgres user"`
+	PasswordFile       string        `yaml:"passwordFile" env:"POSTGRES_PASSWORD_FILE" usage:"The file holding the password of the Postgres user"`
+	SSLMode            string        `yaml:"sslMode" env:"POSTGRES_SSLMODE" usage:"The TLS mode, either disable, allow, prefer, require, verify-ca or verify-full"`
+	SSLRootCert        string        `yaml:"sslRootCert" env:"POSTGRES_SSLROOTCERT" usage:"The file of the CA certificates verifying the server"`
+	SSLCert            string        `yaml:"sslCert" env:"POSTGRES_SSLCERT" usage:"The file of the client certificate"`
+	SSLKey             string        `yaml:"sslKey" env:"POSTGRES_SSLKEY" usage:"The file of the client certificate key"`
+	MaxConns           int           `yaml:"maxConns" env:"POSTGRES_MAX_CONNS" usage:"The maximum size of the pool"`
+	MinConns           int           `yaml:"minConns" env:"POSTGRES_MIN_CONNS" usage:"The number of connections the pool keeps open even when idle"`
+	HealthCheckPeriod  time.Duration `yaml:"healthCheckPeriod" env:"POSTGRES_HEALTH_CHECK_PERIOD" usage:"How often the idle connections are checked"`
+	StatementCacheMode string        `yaml:"statementCacheMode" env:"POSTGRES_STATEMENT_CACHE_MODE" usage:"How the statements are cached, either prepare, describe or disabled for PgBouncer in transaction mode"`
+	ReplicaHosts       []string      `yaml:"replicaHosts" env:"POSTGRES_REPLICA_HOSTS" usage:"The hosts of the read replicas serving the lookups of the shortened URLs, connected to like the primary"`
+}
+
+// DSN returns the URL when given, otherwise it composes the URL of the individual settings with the credentials
+// escaped.
+func (p Postgres) DSN() string {
+	if p.URL != "" {
+		return p.URL
+	}
+
+	query := url.Values{"sslmode": {p.SSLMode}}
+	for key, value := range map[string]string{"sslrootcert": p.SSLRootCert, "sslcert": p.SSLCert, "sslkey": p.SSLKey} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, p.Password),
+		Host:     p.Host,
+		Path:     "/" + p.Database,
+		RawQuery: query.Encode(),
+	}
+
+	return dsn.String()
+}
+
+type Valkey struct {
+	Hosts []string `yaml:"hosts" env:"VALKEY_HOSTS" usage:"The Valkey hosts e.g. 172.16.0.2:6379,172.16.0.3:6379"`
+}
+
+type Shortener struct {
+	Sequence          string        `yaml:"sequence" env:"SNIP_SEQUENCE" usage:"The source of the shortened URL IDs, either valkey or postgres"`
+	SequenceBlockSize int64         `yaml:"sequenceBlockSize" env:"SNIP_SEQUENCE_BLOCK_SIZE" usage:"The number of IDs reserved at once by the valkey sequence"`
+	SlugSecret        string        `yaml:"slugSecret" env:"SNIP_SLUG_SECRET" secret:"true" usage:"The secret obfuscating the generated slugs"`
+	SlugSecretFile    string        `yaml:"slugSecretFile" env:"SNIP_SLUG_SECRET_FILE" usage:"The file holding the secret obfuscating the generated slugs"`
+	AllowedDomains    []string      `yaml:"allowedDomains" env:"SNIP_ALLOWED_DOMAINS" usage:"The only domains the shortened URLs may lead to"`
+	DeniedDomains     []string      `yaml:"deniedDomains" env:"SNIP_DENIED_DOMAINS" usage:"The domains the shortened URLs may not lead to"`
+	AllowedPorts      []int         `yaml:"allowedPorts" env:"SNIP_ALLOWED_PORTS" usage:"The only ports the shortened URLs may lead to"`
+	DeniedPorts       []int         `yaml:"deniedPorts" env:"SNIP_DENIED_PORTS" usage:"The ports the shortened URLs may not lead to"`
+	CacheTTL          time.Duration `yaml:"cacheTTL" env:"SNIP_CACHE_TTL" usage:"How long the found shortened URLs are cached"`
+	CacheMissTTL      time.Duration `yaml:"cacheMissTTL" env:"SNIP_CACHE_MISS_TTL" usage:"How long the missing shortened URLs are cached"`
+}
+
+type Guardian struct {
+	UpdateInterval           time.Duration `yaml:"updateInterval" env:"GUARDIAN_UPDATE_INTERVAL" usage:"How often the feeds are checked for their refresh"`
+	FeedTimeout              time.Duration `yaml:"feedTimeout" env:"GUARDIAN_FEED_TIMEOUT" usage:"The maximum duration of fetching single feed"`
+	URLhausEndpoint          string        `yaml:"urlhausEndpoint" env:"URLHAUS_API_ENDPOINT" usage:"The URL of the URLhaus feed"`
+	URLhausRefreshInterval   time.Duration `yaml:"urlhausRefreshInterval" env:"URLHAUS_REFRESH_INTERVAL" usage:"How often the URLhaus feed is refreshed"`
+	OpenPhishURL             string        `yaml:"openPhishURL" env:"OPENPHISH_FEED_URL" usage:"The URL of the OpenPhish feed"`
+	OpenPhishRefreshInterval time.Duration `yaml:"openPhishRefreshInterval" env:"OPENPHISH_REFRESH_INTERVAL" usage:"How often the OpenPhish feed is refreshed"`
+	PhishTankURL             string        `yaml:"phishTankURL" env:"PHISHTANK_FEED_URL" usage:"The URL of the PhishTank feed"`
+	PhishTankRefreshInterval time.Duration `yaml:"phishTankRefreshInterval" env:"PHISHTANK_REFRESH_INTERVAL" usage:"How often the PhishTank feed is refreshed"`
+	LocalFeedFile            string        `yaml:"localFeedFile" env:"GUARDIAN_LOCAL_FEED_FILE" usage:"The file or directory of the local feed"`
+	LocalFeedRefreshInterval time.Duration `yaml:"localFeedRefreshInterval" env:"GUARDIAN_LOCAL_FEED_REFRESH_INTERVAL" usage:"How often the local feed is refreshed"`
+	ResolveRedirects         bool          `yaml:"resolveRedirects" env:"GUARDIAN_RESOLVE_REDIRECTS" usage:"Whether the redirects of the URLs are followed and checked"`
+	MaxRedirects             int           `yaml:"maxRedirects" env:"GUARDIAN_MAX_REDIRECTS" usage:"The maximum number of followed redirects"`
+	RedirectTimeout          time.Duration `yaml:"redirectTimeout" env:"GUARDIAN_REDIRECT_TIMEOUT" usage:"The maximum duration of following single redirect"`
+	ReadyMaxAge              time.Duration `yaml:"readyMaxAge" env:"GUARDIAN_READY_MAX_AGE" usage:"The maximum age of the feeds before the replica is reported degraded"`
+	OverridesReloadInterval  time.Duration `yaml:"overridesReloadInterval" env:"GUARDIAN_OVERRIDES_RELOAD_INTERVAL" usage:"How often the overrides changed on other replicas are reloaded"`
+	RescanBatchSize          int           `yaml:"rescanBatchSize" env:"GUARDIAN_RESCAN_BATCH_SIZE" usage:"The number of shortened URLs rescanned at once"`
+}
+
+type Jobs struct {
+	LeaseTTL            time.Duration `yaml:"leaseTTL" env:"SNIP_LEASE_TTL" usage:"How long the leadership of the background jobs lasts unless renewed"`
+	LeaseRenewInterval  time.Duration `yaml:"leaseRenewInterval" env:"SNIP_LEASE_RENEW_INTERVAL" usage:"How often the leadership of the background jobs is renewed"`
+	ReaperInterval      time.Duration `yaml:"reaperInterval" env:"SNIP_REAPER_INTERVAL" usage:"How often the expired shortened URLs are archived"`
+	ReaperRetention     time.Duration `yaml:"reaperRetention" env:"SNIP_REAPER_RETENTION" usage:"How long the expired shortened URLs are kept before archiving"`
+	ClicksFlushInterval time.Duration `yaml:"clicksFlushInterval" env:"SNIP_CLICKS_FLUSH_INTERVAL" usage:"How often the click events are flushed into Postgres"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"The exporter of the traces, either none, otlp, console or file"`
+	File     string `yaml:"file" env:"OTEL_TRACES_FILE" usage:"The file the traces are appended to by the file exporter"`
+}
+
+// Default returns the configuration used unless overridden.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8081",
+			AdminAddr:       ":9090",
+			AllowAnonymous:  true,
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 15 * time.Second,
+			DrainDelay:      5 * time.Second,
+			MaxBodyBytes:    1_048_576,
+			RateLimit:       30,
+			RateLimitWindow: time.Minute,
+		},
+		Postgres: Postgres{
+			SSLMode:            "prefer",
+			MaxConns:           10,
+			HealthCheckPeriod:  time.Minute,
+			StatementCacheMode: "prepare",
+		},
+		Shortener: Shortener{
+			Sequence:          "valkey",
+			SequenceBlockSize: 100,
+			CacheTTL:          time.Hour,
+			CacheMissTTL:      time.Minute,
+		},
+		Guardian: Guardian{
+			UpdateInterval: time.Minute,
+			FeedTimeout:    5 * time.Second,
+			// URLhaus asks not to fetch the feed more often than every 5 minutes.
+			URLhausRefreshInterval:   5 * time.Minute,
+			OpenPhishRefreshInterval: time.Hour,
+			PhishTankRefreshInterval: time.Hour,
+			LocalFeedRefreshInterval: time.Minute,
+			ResolveRedirects:         true,
+			MaxRedirects:             5,
+			RedirectTimeout:          3 * time.Second,
+			// The hourly feeds may miss a refresh without the replica becoming unready.
+			ReadyMaxAge:             3 * time.Hour,
+			OverridesReloadInterval: 30 * time.Second,
+			RescanBatchSize:         1000,
+		},
+		Jobs: Jobs{
+			LeaseTTL:            30 * time.Second,
+			LeaseRenewInterval:  10 * time.Second,
+			ReaperInterval:      time.Hour,
+			ReaperRetention:     7 * 24 * time.Hour,
+			ClicksFlushInterval: 10 * time.Second,
+		},
+		Tracing: Tracing{
+			Exporter: "none",
+		},
+	}
+}
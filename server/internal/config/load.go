@@ -0,0 +1,299 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flags holds the values of the configuration flags until they are applied on top of the file and the environment.
+type Flags struct {
+	file   string
+	values map[string]*flagValue
+}
+
+// setting is single leaf field of the configuration.
+type setting struct {
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// flagValue records the raw value of the flag, which is parsed when the configuration is loaded.
+type flagValue struct {
+	defaultValue string
+	isBool       bool
+	raw          string
+	set          bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+
+	return f.defaultValue
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.raw, f.set = raw, true
+
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f != nil && f.isBool
+}
+
+// RegisterFlags defines -config along with the flag of each setting on the flag set.
+func RegisterFlags(flags *flag.FlagSet) *Flags {
+	f := &Flags{values: map[string]*flagValue{}}
+	flags.StringVar(&f.file, "config", "", "A YAML file with the configuration, overrides SNIP_CONFIG")
+	for _, s := range settings(reflect.ValueOf(Default()).Elem(), "") {
+		if s.secret {
+			// The command line is visible to every user of the host, so the secrets have no flags.
+			continue
+		}
+		value := &flagValue{isBool: s.value.Kind() == reflect.Bool, defaultValue: format(s.value)}
+		usage := s.usage
+		if s.env != "" {
+			usage = fmt.Sprintf("%s, overrides %s", usage, s.env)
+		}
+		flags.Var(value, s.flag, usage)
+		f.values[s.path] = value
+	}
+
+	return f
+}
+
+// Load reads the configuration from the file, the environment and the flags in this order of precedence, the last
+// one winning. The secrets given by file are read unless given directly. The configuration is not validated.
+func Load(flags *Flags, getenv func(string) string) (*Config, error) {
+	c := Default()
+	file := flags.file
+	if file == "" {
+		file = getenv("SNIP_CONFIG")
+	}
+	if file != "" {
+		if err := decodeFile(file, c); err != nil {
+			return nil, err
+		}
+	}
+
+	all := settings(reflect.ValueOf(c).Elem(), "")
+	for _, s := range all {
+		if s.env == "" {
+			continue
+		}
+		if raw := getenv(s.env); strings.TrimSpace(raw) != "" {
+			if err := parse(s.value, raw); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range all {
+		if value := flags.values[s.path]; value != nil && value.set {
+			if err := parse(s.value, value.raw); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	var err error
+	if c.Postgres.URL == "" && c.Postgres.URLFile != "" {
+		if c.Postgres.URL, err = readSecretFile(c.Postgres.URLFile); err != nil {
+			return nil, fmt.Errorf("reading postgres url: %w", err)
+		}
+	}
+	if c.Postgres.Password == "" && c.Postgres.PasswordFile != "" {
+		if c.Postgres.Password, err = readSecretFile(c.Postgres.PasswordFile); err != nil {
+			return nil, fmt.Errorf("reading postgres password: %w", err)
+		}
+	}
+	if c.Shortener.SlugSecret == "" && c.Shortener.SlugSecretFile != "" {
+		if c.Shortener.SlugSecret, err = readSecretFile(c.Shortener.SlugSecretFile); err != nil {
+			return nil, fmt.Errorf("reading slug secret: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Print writes the configuration as YAML with the secrets redacted, so that it can be used as configuration file.
+func Print(w io.Writer, c *Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(toNode(reflect.ValueOf(c).Elem())); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func decodeFile(name string, c *Config) error {
+	file, err := os.Open(filepath.Clean(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decoding %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// settings returns the leaf fields of the struct, the nested structs are prefixed by their YAML key.
+func settings(v reflect.Value, prefix string) []setting {
+	var result []setting
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		path := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if field.Type.Kind() == reflect.Struct {
+			result = append(result, settings(v.Field(i), path)...)
+			continue
+		}
+
+		flagName := field.Tag.Get("flag")
+		if flagName == "" {
+			flagName = path
+		}
+		result = append(result, setting{
+			path:   path,
+			env:    field.Tag.Get("env"),
+			flag:   flagName,
+			usage:  field.Tag.Get("usage"),
+			secret: field.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+
+	return result
+}
+
+// parse sets the value from its textual form, the lists are comma separated.
+func parse(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch p := value.Addr().Interface().(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*p = i
+	case *int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = i
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		*p = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	case *[]int:
+		*p = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			i, err := strconv.Atoi(item)
+			if err != nil {
+				return err
+			}
+			*p = append(*p, i)
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+
+	return nil
+}
+
+// format returns the textual form of the value accepted by parse.
+func format(value reflect.Value) string {
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	case []int:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = strconv.Itoa(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toNode converts the struct into YAML mapping, the durations are written in their textual form and the secrets
+// are redacted.
+func toNode(v reflect.Value) *yaml.Node {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: strings.Split(field.Tag.Get("yaml"), ",")[0]}
+		value := &yaml.Node{}
+		switch fieldValue := v.Field(i); {
+		case field.Type.Kind() == reflect.Struct:
+			value = toNode(fieldValue)
+		case field.Tag.Get("secret") == "true" && !fieldValue.IsZero():
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: "REDACTED"}
+		case field.Type == reflect.TypeFor[time.Duration]():
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: format(fieldValue)}
+		default:
+			// Encoding the basic types and their slices can't fail.
+			_ = value.Encode(fieldValue.Interface())
+		}
+		mapping.Content = append(mapping.Content, key, value)
+	}
+
+	return mapping
+}
+
+func readSecretFile(name string) (string, error) {
+	content, err := os.ReadFile(filepath.Clean(name))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
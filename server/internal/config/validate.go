@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/tracing"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Validate reports all the invalid settings at once, each named by its YAML path and environment variable.
+func (c *Config) Validate() error {
+	var problems []error
+	check := func(ok bool, path, message string) {
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s %s", c.name(path), message))
+		}
+	}
+	positive := func(d time.Duration, path string) {
+		check(d > 0, path, "must be positive")
+	}
+
+	check(c.Server.Addr != "", "server.addr", "is required")
+	check(c.Server.Hostname != "", "server.hostname", "is required")
+	positive(c.Server.ReadTimeout, "server.readTimeout")
+	positive(c.Server.WriteTimeout, "server.writeTimeout")
+	positive(c.Server.IdleTimeout, "server.idleTimeout")
+	positive(c.Server.ShutdownTimeout, "server.shutdownTimeout")
+	check(c.Server.DrainDelay >= 0, "server.drainDelay", "must not be negative")
+	check(c.Server.MaxBodyBytes > 0, "server.maxBodyBytes", "must be positive")
+	check(c.Server.RateLimit > 0, "server.rateLimit", "must be positive")
+	positive(c.Server.RateLimitWindow, "server.rateLimitWindow")
+
+	if c.Postgres.URL == "" {
+		check(c.Postgres.Host != "", "postgres.host", "is required unless postgres.url is given")
+		check(c.Postgres.User != "", "postgres.user", "is required unless postgres.url is given")
+		check(c.Postgres.Database != "", "postgres.database", "is required unless postgres.url is given")
+		check(c.Postgres.Password != "", "postgres.password", "is required, either directly or by postgres.passwordFile")
+		sslModes := []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+		check(slices.Contains(sslModes, c.Postgres.SSLMode), "postgres.sslMode", "must be one of disable, allow, prefer, require, verify-ca or verify-full")
+		check((c.Postgres.SSLCert == "") == (c.Postgres.SSLKey == ""), "postgres.sslKey", "must be given along with postgres.sslCert")
+	}
+	check(c.Postgres.MaxConns > 0, "postgres.maxConns", "must be positive")
+	check(c.Postgres.MinConns >= 0 && c.Postgres.MinConns <= c.Postgres.MaxConns, "postgres.minConns", "must be within 0 and postgres.maxConns")
+	positive(c.Postgres.HealthCheckPeriod, "postgres.healthCheckPeriod")
+	statementCacheModes := []string{"prepare", "describe", "disabled"}
+	check(slices.Contains(statementCacheModes, c.Postgres.StatementCacheMode), "postgres.statementCacheMode", "must be one of prepare, describe or disabled")
+
+	check(len(c.Valkey.Hosts) > 0, "valkey.hosts", "is required")
+
+	check(slices.Contains([]string{"valkey", "postgres"}, c.Shortener.Sequence), "shortener.sequence", "must be either valkey or postgres")
+	check(c.Shortener.SequenceBlockSize > 0, "shortener.sequenceBlockSize", "must be positive")
+	for _, port := range slices.Concat(c.Shortener.AllowedPorts, c.Shortener.DeniedPorts) {
+		check(port > 0 && port <= 65535, "shortener.allowedPorts", fmt.Sprintf("and shortener.deniedPorts must be within 1-65535, got %d", port))
+	}
+	positive(c.Shortener.CacheTTL, "shortener.cacheTTL")
+	positive(c.Shortener.CacheMissTTL, "shortener.cacheMissTTL")
+
+	positive(c.Guardian.UpdateInterval, "guardian.updateInterval")
+	positive(c.Guardian.FeedTimeout, "guardian.feedTimeout")
+	positive(c.Guardian.URLhausRefreshInterval, "guardian.urlhausRefreshInterval")
+	positive(c.Guardian.OpenPhishRefreshInterval, "guardian.openPhishRefreshInterval")
+	positive(c.Guardian.PhishTankRefreshInterval, "guardian.phishTankRefreshInterval")
+	positive(c.Guardian.LocalFeedRefreshInterval, "guardian.localFeedRefreshInterval")
+	check(c.Guardian.MaxRedirects > 0, "guardian.maxRedirects", "must be positive")
+	positive(c.Guardian.RedirectTimeout, "guardian.redirectTimeout")
+	positive(c.Guardian.ReadyMaxAge, "guardian.readyMaxAge")
+	positive(c.Guardian.OverridesReloadInterval, "guardian.overridesReloadInterval")
+	check(c.Guardian.RescanBatchSize > 0, "guardian.rescanBatchSize", "must be positive")
+
+	positive(c.Jobs.LeaseTTL, "jobs.leaseTTL")
+	positive(c.Jobs.LeaseRenewInterval, "jobs.leaseRenewInterval")
+	check(c.Jobs.LeaseRenewInterval < c.Jobs.LeaseTTL, "jobs.leaseRenewInterval", "must be shorter than jobs.leaseTTL")
+	positive(c.Jobs.ReaperInterval, "jobs.reaperInterval")
+	positive(c.Jobs.ReaperRetention, "jobs.reaperRetention")
+	positive(c.Jobs.ClicksFlushInterval, "jobs.clicksFlushInterval")
+
+	exporters := []string{tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterConsole, tracing.ExporterFile}
+	check(slices.Contains(exporters, c.Tracing.Exporter), "tracing.exporter", "must be one of none, otlp, console or file")
+	check(c.Tracing.Exporter != tracing.ExporterFile || c.Tracing.File != "", "tracing.file", "is required by the file exporter")
+
+	return errors.Join(problems...)
+}
+
+// name returns the YAML path of the setting along with its environment variable.
+func (c *Config) name(path string) string {
+	for _, s := range settings(reflect.ValueOf(c).Elem(), "") {
+		if s.path == path && s.env != "" {
+			return fmt.Sprintf("%s (%s)", path, s.env)
+		}
+	}
+
+	return path
+}
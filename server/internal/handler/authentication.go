@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"net/http"
+	"strings"
+)
+
+type ownerIdCtxKey struct{}
+
+// OwnerId returns the owner authenticated by the API key of the request or empty string for anonymous requests.
+func OwnerId(ctx context.Context) string {
+	ownerId, _ := ctx.Value(ownerIdCtxKey{}).(string)
+	return ownerId
+}
+
+// Authenticate authenticates requests bearing API key in the Authorization header. Requests without
+// the header are let through as anonymous only when allowAnonymous is set.
+func Authenticate(authenticator service.APIKeyAuthenticator, allowAnonymous bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization := r.Header.Get("Authorization")
+			if authorization == "" {
+				if allowAnonymous {
+					next.ServeHTTP(w, r)
+					return
+				}
+				unauthorized(w)
+				return
+			}
+
+			scheme, key, found := strings.Cut(authorization, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") {
+				unauthorized(w)
+				return
+			}
+
+			ownerId, err := authenticator.Authenticate(r.Context(), strings.TrimSpace(key))
+			if err != nil {
+				if errors.Is(err, service.ErrInvalidAPIKey) {
+					unauthorized(w)
+					return
+				}
+
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ownerIdCtxKey{}, ownerId)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snip"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubAPIKeyAuthenticator authenticates the given keys, the owners by the keys.
+type stubAPIKeyAuthenticator struct {
+	service.APIKeyAuthenticator
+	keys map[string]string
+}
+
+func (s *stubAPIKeyAuthenticator) Authenticate(_ context.Context, key string) (string, error) {
+	ownerId, ok := s.keys[key]
+	if !ok {
+		return "", service.ErrInvalidAPIKey
+	}
+	return ownerId, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	authenticator := &stubAPIKeyAuthenticator{keys: map[string]string{"snip_valid": "marketing"}}
+	tests := []struct {
+		name           string
+		authorization  string
+		allowAnonymous bool
+		wantCode       int
+		wantOwnerId    string
+	}{
+		{name: "anonymous allowed", allowAnonymous: true, wantCode: http.StatusOK},
+		{name: "anonymous denied", allowAnonymous: false, wantCode: http.StatusUnauthorized},
+		{name: "valid key", authorization: "Bearer snip_valid", wantCode: http.StatusOK, wantOwnerId: "marketing"},
+		{name: "invalid key", authorization: "Bearer snip_invalid", allowAnonymous: true, wantCode: http.StatusUnauthorized},
+		{name: "unsupported scheme", authorization: "Basic snip_valid", allowAnonymous: true, wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotOwnerId string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotOwnerId = OwnerId(r.Context())
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			res := httptest.NewRecorder()
+
+			Authenticate(authenticator, tt.allowAnonymous)(next).ServeHTTP(res, req)
+
+			if res.Code != tt.wantCode {
+				t.Errorf("got %d, want %d", res.Code, tt.wantCode)
+			}
+			if gotOwnerId != tt.wantOwnerId {
+				t.Errorf("got owner %q, want %q", gotOwnerId, tt.wantOwnerId)
+			}
+		})
+	}
+}
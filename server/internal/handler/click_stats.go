@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+func ClickStats(tracker service.ClickTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slug := r.PathValue("slug")
+		stats, err := tracker.Stats(ctx, slug, OwnerId(ctx))
+		if err != nil {
+			if errors.Is(err, service.ErrNotOwner) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			if errors.Is(err, store.ErrShortenedURLNotFound) || errors.Is(err, service.ErrIllegalSlug) {
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if err = encode[*model.ClickStatsRes](w, http.StatusOK, stats, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func clickEvent(r *http.Request, slug string) model.ClickEvent {
+	return model.ClickEvent{
+		Slug:       slug,
+		Referrer:   r.Referer(),
+		UserAgent:  r.UserAgent(),
+		ClientIP:   coarseIP(r.RemoteAddr),
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+// coarseIP truncates the client IP to its /24 (IPv4) or /48 (IPv6) network, so that clients cannot be singled out.
+func coarseIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		// middleware.RealIP replaces the remote address with the bare IP.
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+
+	bits := 48
+	if addr.Unmap().Is4() {
+		addr = addr.Unmap()
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+
+	return prefix.String()
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClickStats(t *testing.T) {
+	t.Run("stats of existing slug", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/shortened-url/abcd/stats", nil)
+		res := httptest.NewRecorder()
+		trackerStub := &stubClickTracker{stats: &model.ClickStatsRes{Slug: "abcd", TotalClicks: 42}}
+
+		ClickStats(trackerStub).ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("got %d, want %d", res.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("stats of unknown slug", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/shortened-url/abcd/stats", nil)
+		res := httptest.NewRecorder()
+		trackerStub := &stubClickTracker{err: store.ErrShortenedURLNotFound}
+
+		ClickStats(trackerStub).ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("got %d, want %d", res.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestCoarseIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.57":          "203.0.113.0/24",
+		"203.0.113.57:43210":    "203.0.113.0/24",
+		"2001:db8:1:2:3:4:5:6":  "2001:db8:1::/48",
+		"[2001:db8:1:2::6]:443": "2001:db8:1::/48",
+		"not an ip":             "",
+	}
+	for remoteAddr, want := range tests {
+		if got := coarseIP(remoteAddr); got != want {
+			t.Errorf("coarseIP(%q) = %q, want %q", remoteAddr, got, want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"net/http"
+)
+
+type guardianHealthRes struct {
+	Providers []model.ThreatProviderHealth `json:"providers"`
+}
+
+// GuardianHealth reports the health of each threat provider, responding with 503 when any of them is unhealthy.
+func GuardianHealth(guardian service.URLGuardian) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := guardianHealthRes{Providers: guardian.Health(r.Context())}
+		status := http.StatusOK
+		for _, provider := range payload.Providers {
+			if !provider.Healthy {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		if err := encode[guardianHealthRes](w, status, payload, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
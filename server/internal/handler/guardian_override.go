@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// RequireAdmin rejects requests of owners other than the given admins. It has to be preceded by RequireOwner.
+func RequireAdmin(admins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(admins, OwnerId(r.Context())) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ListGuardianOverrides(overrides service.GuardianOverrides) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := overrides.List(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		payload := &model.GuardianOverrideListRes{Items: items}
+		if err = encode[*model.GuardianOverrideListRes](w, http.StatusOK, payload, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func CreateGuardianOverride(overrides service.GuardianOverrides, v *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		createReq, problems, err := decodeValidatable[model.CreateGuardianOverrideReq](r, v)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if len(problems) > 0 {
+			if err = encode[map[string]string](w, http.StatusBadRequest, problems, nil); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		override, err := overrides.Create(ctx, OwnerId(ctx), createReq)
+		if err != nil {
+			if errors.Is(err, service.ErrOverrideNotApplicable) {
+				problems = map[string]string{"granularity": "The 'granularity' doesn't apply to the 'url'."}
+				if err = encode[map[string]string](w, http.StatusBadRequest, problems, nil); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if errors.Is(err, store.ErrGuardianOverrideExists) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err = encode[*model.GuardianOverride](w, http.StatusCreated, override, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DeleteGuardianOverride(overrides service.GuardianOverrides) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		if err = overrides.Delete(ctx, OwnerId(ctx), id); err != nil {
+			if errors.Is(err, store.ErrGuardianOverrideNotFound) {
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListGuardianOverrideAudit(overrides service.GuardianOverrides) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var limit int
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+				problems := map[string]string{"limit": "The 'limit' must be positive integer."}
+				if err = encode[map[string]string](w, http.StatusBadRequest, problems, nil); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+
+		items, err := overrides.Audit(r.Context(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		payload := &model.GuardianOverrideAuditListRes{Items: items}
+		if err = encode[*model.GuardianOverrideAuditListRes](w, http.StatusOK, payload, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
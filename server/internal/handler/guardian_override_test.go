@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubGuardianOverrides struct {
+	createCalls int
+	author      string
+	err         error
+}
+
+func (s *stubGuardianOverrides) List(_ context.Context) ([]*model.GuardianOverride, error) {
+	return nil, s.err
+}
+
+func (s *stubGuardianOverrides) Create(_ context.Context, author string, createReq model.CreateGuardianOverrideReq) (*model.GuardianOverride, error) {
+	s.createCalls++
+	s.author = author
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.GuardianOverride{Id: 1, Kind: createReq.Kind, URL: createReq.URL, Author: author}, nil
+}
+
+func (s *stubGuardianOverrides) Delete(_ context.Context, author string, id int64) error {
+	s.author = author
+	return s.err
+}
+
+func (s *stubGuardianOverrides) Audit(_ context.Context, limit int) ([]*model.GuardianOverrideAudit, error) {
+	return nil, s.err
+}
+
+func (s *stubGuardianOverrides) Verdicts(_ context.Context, urls []string) ([]service.OverrideVerdict, error) {
+	return make([]service.OverrideVerdict, len(urls)), s.err
+}
+
+func TestRequireAdmin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := map[string]int{
+		"security":  http.StatusOK,
+		"marketing": http.StatusForbidden,
+	}
+	for ownerId, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/guardian/overrides", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ownerIdCtxKey{}, ownerId))
+		res := httptest.NewRecorder()
+
+		RequireAdmin([]string{"security"})(next).ServeHTTP(res, req)
+
+		if res.Code != want {
+			t.Errorf("owner %q got %d, want %d", ownerId, res.Code, want)
+		}
+	}
+}
+
+func TestCreateGuardianOverride(t *testing.T) {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	tests := map[string]struct {
+		createReq model.CreateGuardianOverrideReq
+		err       error
+		want      int
+	}{
+		"allow url": {
+			createReq: model.CreateGuardianOverrideReq{Kind: "allow", URL: "https://example.com/report", Reason: "False positive"},
+			want:      http.StatusCreated,
+		},
+		"unknown kind": {
+			createReq: model.CreateGuardianOverrideReq{Kind: "block", URL: "https://example.com/report", Reason: "Phishing"},
+			want:      http.StatusBadRequest,
+		},
+		"missing reason": {
+			createReq: model.CreateGuardianOverrideReq{Kind: "deny", URL: "https://example.com/report"},
+			want:      http.StatusBadRequest,
+		},
+		"inapplicable granularity": {
+			createReq: model.CreateGuardianOverrideReq{Kind: "deny", URL: "http://192.0.2.1/", Granularity: "domain", Reason: "Phishing"},
+			err:       service.ErrOverrideNotApplicable,
+			want:      http.StatusBadRequest,
+		},
+		"existing override": {
+			createReq: model.CreateGuardianOverrideReq{Kind: "deny", URL: "https://example.com/report", Reason: "Phishing"},
+			err:       store.ErrGuardianOverrideExists,
+			want:      http.StatusConflict,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			payload, err := json.Marshal(tt.createReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/guardian/overrides", bytes.NewBuffer(payload))
+			req = req.WithContext(context.WithValue(req.Context(), ownerIdCtxKey{}, "security"))
+			res := httptest.NewRecorder()
+			overridesStub := &stubGuardianOverrides{err: tt.err}
+
+			CreateGuardianOverride(overridesStub, validate).ServeHTTP(res, req)
+
+			if res.Code != tt.want {
+				t.Errorf("got %d, want %d", res.Code, tt.want)
+			}
+			if overridesStub.createCalls > 0 && overridesStub.author != "security" {
+				t.Errorf("got author %q, want %q", overridesStub.author, "security")
+			}
+		})
+	}
+}
+
+func TestDeleteGuardianOverride(t *testing.T) {
+	tests := map[string]struct {
+		id   string
+		err  error
+		want int
+	}{
+		"existing override": {id: "1", want: http.StatusNoContent},
+		"missing override":  {id: "2", err: store.ErrGuardianOverrideNotFound, want: http.StatusNotFound},
+		"illegal id":        {id: "abc", want: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/guardian/overrides/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			res := httptest.NewRecorder()
+
+			DeleteGuardianOverride(&stubGuardianOverrides{err: tt.err}).ServeHTTP(res, req)
+
+			if res.Code != tt.want {
+				t.Errorf("got %d, want %d", res.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestListGuardianOverrideAudit(t *testing.T) {
+	tests := map[string]int{
+		"/api/v1/admin/guardian/audit":          http.StatusOK,
+		"/api/v1/admin/guardian/audit?limit=20": http.StatusOK,
+		"/api/v1/admin/guardian/audit?limit=0":  http.StatusBadRequest,
+	}
+	for target, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+
+		ListGuardianOverrideAudit(&stubGuardianOverrides{}).ServeHTTP(res, req)
+
+		if res.Code != want {
+			t.Errorf("GET %s got %d, want %d", target, res.Code, want)
+		}
+	}
+}
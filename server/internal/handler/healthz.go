@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
 	"net/http"
 )
 
@@ -14,3 +16,26 @@ func Healthz() http.HandlerFunc {
 		}
 	}
 }
+
+// Livez reports that the process is able to serve requests. It doesn't depend on anything, so that the replica
+// isn't restarted because of outages of its dependencies.
+func Livez() http.HandlerFunc {
+	return Healthz()
+}
+
+// Readyz reports the health of each dependency, responding with 503 when any of them is unhealthy or the replica
+// is shutting down. Unhealthy degradable dependencies are only reported.
+func Readyz(readiness service.Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := readiness.Check(r.Context())
+		status := http.StatusOK
+		if !payload.Ready {
+			status = http.StatusServiceUnavailable
+		}
+
+		if err := encode[model.ReadinessRes](w, status, payload, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
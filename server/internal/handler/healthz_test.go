@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/aboyadzhiev/snip/server/internal/model"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -16,3 +19,54 @@ func TestHealthz(t *testing.T) {
 		t.Errorf("got %d, want %d", w.Code, http.StatusOK)
 	}
 }
+
+type stubReadiness struct {
+	res model.ReadinessRes
+}
+
+func (s *stubReadiness) Check(context.Context) model.ReadinessRes {
+	return s.res
+}
+
+func (s *stubReadiness) Drain() {
+	s.res.Ready, s.res.Draining = false, true
+}
+
+func TestReadyz(t *testing.T) {
+	tests := map[string]struct {
+		res  model.ReadinessRes
+		want int
+	}{
+		"ready": {
+			res:  model.ReadinessRes{Ready: true, Dependencies: []model.DependencyHealth{{Name: "postgres", Healthy: true}}},
+			want: http.StatusOK,
+		},
+		"degraded": {
+			res:  model.ReadinessRes{Ready: true, Degraded: true, Dependencies: []model.DependencyHealth{{Name: "guardian", Degradable: true}}},
+			want: http.StatusOK,
+		},
+		"unhealthy dependency": {
+			res:  model.ReadinessRes{Dependencies: []model.DependencyHealth{{Name: "valkey", Error: "connection refused"}}},
+			want: http.StatusServiceUnavailable,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			w := httptest.NewRecorder()
+
+			Readyz(&stubReadiness{res: tt.res}).ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("got %d, want %d", w.Code, tt.want)
+			}
+			var res model.ReadinessRes
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Dependencies) != len(tt.res.Dependencies) {
+				t.Errorf("got %d dependencies, want %d", len(res.Dependencies), len(tt.res.Dependencies))
+			}
+		})
+	}
+}
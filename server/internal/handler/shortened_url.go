@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RequireOwner rejects anonymous requests regardless of whether Authenticate lets them through.
+func RequireOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if OwnerId(r.Context()) == "" {
+			unauthorized(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ListShortenedURLs(shortener service.URLShortener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		listReq, problems := decodeListShortenedURLsReq(r.URL.Query())
+		if len(problems) > 0 {
+			if err := encode[map[string]string](w, http.StatusBadRequest, problems, nil); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		listRes, err := shortener.List(ctx, OwnerId(ctx), listReq)
+		if err != nil {
+			if errors.Is(err, service.ErrIllegalCursor) {
+				problems = map[string]string{"cursor": "The 'cursor' must be a value of previously returned 'nextCursor'."}
+				if err = encode[map[string]string](w, http.StatusBadRequest, problems, nil); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err = encode[*model.ShortenedURLListRes](w, http.StatusOK, listRes, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetShortenedURL(shortener service.URLShortener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		shortenedURLRes, err := shortener.Get(ctx, OwnerId(ctx), r.PathValue("slug"))
+		if err != nil {
+			writeManagementError(w, err)
+			return
+		}
+
+		if err = encode[*model.ShortenedURLRes](w, http.StatusOK, shortenedURLRes, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func UpdateShortenedURL(shortener service.URLShortener, v *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		updateReq, problems, err := decodeValidatable[model.UpdateShortenedURLReq](r, v)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if len(problems) > 0 {
+			if err = encode[map[string]string](w, http.StatusBadRequest, problems, nil); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		shortenedURLRes, err := shortener.Update(ctx, OwnerId(ctx), r.PathValue("slug"), updateReq)
+		if err != nil {
+			writeManagementError(w, err)
+			return
+		}
+
+		if err = encode[*model.ShortenedURLRes](w, http.StatusOK, shortenedURLRes, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DeleteShortenedURL(shortener service.URLShortener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := shortener.Delete(ctx, OwnerId(ctx), r.PathValue("slug")); err != nil {
+			writeManagementError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeManagementError(w http.ResponseWriter, err error) {
+	if status, rejectionRes := rejection(err); rejectionRes != nil {
+		if err = encode[*model.RejectionRes](w, status, rejectionRes, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	switch {
+	case errors.Is(err, store.ErrShortenedURLNotFound) || errors.Is(err, service.ErrIllegalSlug):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, service.ErrNotOwner):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func decodeListShortenedURLsReq(query url.Values) (model.ListShortenedURLsReq, map[string]string) {
+	problems := map[string]string{}
+	listReq := model.ListShortenedURLsReq{
+		Cursor:      query.Get("cursor"),
+		URLContains: query.Get("url"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 {
+			problems["limit"] = "The 'limit' must be positive integer."
+		}
+		listReq.Limit = l
+	}
+
+	parseTime := func(field string) *time.Time {
+		value := query.Get(field)
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			problems[field] = "The '" + field + "' must be RFC 3339 timestamp."
+			return nil
+		}
+		return &t
+	}
+	listReq.CreatedAfter = parseTime("createdAfter")
+	listReq.CreatedBefore = parseTime("createdBefore")
+
+	return listReq, problems
+}
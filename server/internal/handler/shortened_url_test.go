@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireOwner(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("anonymous request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/shortened-url", nil)
+		res := httptest.NewRecorder()
+
+		RequireOwner(next).ServeHTTP(res, req)
+
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("got %d, want %d", res.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("authenticated request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/shortened-url", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ownerIdCtxKey{}, "marketing"))
+		res := httptest.NewRecorder()
+
+		RequireOwner(next).ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("got %d, want %d", res.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestListShortenedURLs(t *testing.T) {
+	tests := map[string]int{
+		"/api/v1/shortened-url": http.StatusOK,
+		"/api/v1/shortened-url?limit=10&createdAfter=2025-01-01T00:00:00Z": http.StatusOK,
+		"/api/v1/shortened-url?limit=-1":                                   http.StatusBadRequest,
+		"/api/v1/shortened-url?createdBefore=yesterday":                    http.StatusBadRequest,
+	}
+	for target, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+
+		ListShortenedURLs(&stubURLShortener{}).ServeHTTP(res, req)
+
+		if res.Code != want {
+			t.Errorf("GET %s got %d, want %d", target, res.Code, want)
+		}
+	}
+}
+
+func TestGetShortenedURL(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want int
+	}{
+		"owned slug":     {want: http.StatusOK},
+		"unknown slug":   {err: store.ErrShortenedURLNotFound, want: http.StatusNotFound},
+		"someone's slug": {err: service.ErrNotOwner, want: http.StatusForbidden},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/shortened-url/abcd", nil)
+			res := httptest.NewRecorder()
+
+			GetShortenedURL(&stubURLShortener{manageErr: tt.err}).ServeHTTP(res, req)
+
+			if res.Code != tt.want {
+				t.Errorf("got %d, want %d", res.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteShortenedURL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/shortened-url/abcd", nil)
+	res := httptest.NewRecorder()
+
+	DeleteShortenedURL(&stubURLShortener{}).ServeHTTP(res, req)
+
+	if res.Code != http.StatusNoContent {
+		t.Errorf("got %d, want %d", res.Code, http.StatusNoContent)
+	}
+}
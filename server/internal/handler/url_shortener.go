@@ -5,13 +5,18 @@ import (
 	"github.com/aboyadzhiev/snip/server/internal/model"
 	"github.com/aboyadzhiev/snip/server/internal/service"
 	"github.com/aboyadzhiev/snip/server/internal/store"
+	"github.com/aboyadzhiev/snip/server/internal/tracing"
 	"github.com/go-playground/validator/v10"
 	"net/http"
 )
 
+// quarantinedWarning is served in place of the redirect of quarantined shortened URLs.
+const quarantinedWarning = "Warning: the destination of this link has been reported as malicious, so the link has been disabled."
+
 func ShortenURL(shortener service.URLShortener, v *validator.Validate) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		ctx, w, end := tracing.StartHandler(w, r, "handler.ShortenURL")
+		defer end()
 		shortenURLReq, problems, err := decodeValidatable[model.ShortenURLReq](r, v)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -25,10 +30,18 @@ func ShortenURL(shortener service.URLShortener, v *validator.Validate) http.Hand
 			return
 		}
 
-		slug, err := shortener.Shorten(ctx, shortenURLReq.URL)
+		shortenURLReq.OwnerId = OwnerId(ctx)
+		slug, err := shortener.Shorten(ctx, shortenURLReq)
 		if err != nil {
-			if errors.Is(err, service.ErrMaliciousURLDetected) {
-				w.WriteHeader(http.StatusNotAcceptable)
+			if status, rejectionRes := rejection(err); rejectionRes != nil {
+				if err = encode[*model.RejectionRes](w, status, rejectionRes, nil); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if errors.Is(err, service.ErrSlugAlreadyTaken) {
+				w.WriteHeader(http.StatusConflict)
 				return
 			}
 
@@ -44,9 +57,10 @@ func ShortenURL(shortener service.URLShortener, v *validator.Validate) http.Hand
 	}
 }
 
-func Resolve(shortener service.URLShortener) http.HandlerFunc {
+func Resolve(shortener service.URLShortener, tracker service.ClickTracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		ctx, w, end := tracing.StartHandler(w, r, "handler.Resolve")
+		defer end()
 		slug := r.PathValue("slug")
 		url, err := shortener.Resolve(ctx, slug)
 		if err != nil {
@@ -55,9 +69,34 @@ func Resolve(shortener service.URLShortener) http.HandlerFunc {
 				return
 			}
 
+			if errors.Is(err, service.ErrShortenedURLExpired) || errors.Is(err, service.ErrShortenedURLDeleted) {
+				http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+				return
+			}
+
+			if errors.Is(err, service.ErrShortenedURLQuarantined) {
+				http.Error(w, quarantinedWarning, http.StatusUnavailableForLegalReasons)
+				return
+			}
+
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+		tracker.Track(clickEvent(r, slug))
 		http.Redirect(w, r, url, http.StatusFound)
 	}
 }
+
+// rejection translates the errors of URLs refused by the guardian or the destination policy to the response status
+// and body. The body is nil for other errors.
+func rejection(err error) (int, *model.RejectionRes) {
+	var violation *service.PolicyViolation
+	switch {
+	case errors.As(err, &violation):
+		return http.StatusUnprocessableEntity, &model.RejectionRes{Reason: violation.Reason, Message: violation.Message}
+	case errors.Is(err, service.ErrMaliciousURLDetected):
+		return http.StatusNotAcceptable, &model.RejectionRes{Reason: service.ReasonMaliciousURL, Message: "The URL has been reported as malicious."}
+	default:
+		return 0, nil
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/go-playground/validator/v10"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// batchChunkSize is the number of URLs shortened at once. The results are streamed back after each chunk.
+const batchChunkSize = 500
+const maxBatchSize = 10_000
+
+// batchChunkTimeout is the time each chunk is given to be read, shortened and written back, since the server's
+// timeouts are too short for the whole batch.
+const batchChunkTimeout = 30 * time.Second
+
+// MaxBatchBodyBytes is the size of the largest batch, the maximum URL length plus room for the rest of the item.
+const MaxBatchBodyBytes = maxBatchSize * (4096 + 512)
+
+// ShortenURLBatch shortens the URLs of JSON array or NDJSON stream of model.ShortenURLReq. The results are streamed
+// back as NDJSON of model.ShortenURLBatchItemRes in the order of the request.
+func ShortenURLBatch(shortener service.URLShortener, v *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		array := mediaType != "application/x-ndjson"
+		if array {
+			if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		controller := http.NewResponseController(w)
+
+		ownerId := OwnerId(ctx)
+		index := 0
+		for done := false; !done; {
+			// The deadlines cannot be extended when the writer does not support it, which leaves the server's.
+			deadline := time.Now().Add(batchChunkTimeout)
+			_ = controller.SetReadDeadline(deadline)
+			_ = controller.SetWriteDeadline(deadline)
+
+			results := make([]*model.ShortenURLBatchItemRes, 0, batchChunkSize)
+			shortenURLReqs := make([]model.ShortenURLReq, 0, batchChunkSize)
+			pending := make([]*model.ShortenURLBatchItemRes, 0, batchChunkSize)
+
+			for len(results) < batchChunkSize {
+				if !decoder.More() {
+					done = true
+					break
+				}
+
+				result := &model.ShortenURLBatchItemRes{Index: index}
+				index++
+				results = append(results, result)
+				if index > maxBatchSize {
+					result.Status = http.StatusRequestEntityTooLarge
+					result.Problems = map[string]string{"item": fmt.Sprintf("The batch cannot exceed %d items.", maxBatchSize)}
+					done = true
+					break
+				}
+
+				var shortenURLReq model.ShortenURLReq
+				if err := decoder.Decode(&shortenURLReq); err != nil {
+					// The rest of the stream cannot be decoded reliably.
+					result.Status = http.StatusBadRequest
+					result.Problems = map[string]string{"item": "The item is not a valid JSON object."}
+					done = true
+					break
+				}
+
+				if problems := shortenURLReq.Validate(ctx, v); len(problems) > 0 {
+					result.Status = http.StatusBadRequest
+					result.Problems = problems
+					continue
+				}
+				shortenURLReq.OwnerId = ownerId
+				shortenURLReqs = append(shortenURLReqs, shortenURLReq)
+				pending = append(pending, result)
+			}
+
+			if len(shortenURLReqs) > 0 {
+				shortenResults, err := shortener.ShortenBatch(ctx, shortenURLReqs)
+				for i, result := range pending {
+					if err != nil {
+						result.Status = http.StatusInternalServerError
+						continue
+					}
+					result.Status, result.ShortenURL = batchItemStatus(shortenResults[i]), shortenResults[i].ShortenURL
+					_, result.Rejection = rejection(shortenResults[i].Err)
+				}
+			}
+
+			for _, result := range results {
+				if err := encoder.Encode(result); err != nil {
+					return
+				}
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func batchItemStatus(shortenResult model.ShortenResult) int {
+	switch {
+	case shortenResult.Err == nil:
+		return http.StatusCreated
+	case errors.Is(shortenResult.Err, service.ErrDestinationNotAllowed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(shortenResult.Err, service.ErrMaliciousURLDetected):
+		return http.StatusNotAcceptable
+	case errors.Is(shortenResult.Err, service.ErrUnsupportedInBatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
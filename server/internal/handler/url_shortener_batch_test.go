@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShortenURLBatch(t *testing.T) {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  []int
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        `[{"url":"https://www.fsf.org/blogs/community/"},{"url":"ftp://ftp.example.com"}]`,
+			wantStatus:  []int{http.StatusCreated, http.StatusBadRequest},
+		},
+		{
+			name:        "ndjson stream",
+			contentType: "application/x-ndjson",
+			body:        "{\"url\":\"https://www.fsf.org/blogs/community/\"}\n{\"url\":\"https://www.gnu.org/philosophy/\"}\n",
+			wantStatus:  []int{http.StatusCreated, http.StatusCreated},
+		},
+		{
+			name:        "malformed item",
+			contentType: "application/x-ndjson",
+			body:        "{\"url\":\"https://www.fsf.org/blogs/community/\"}\n{\"url\":\n",
+			wantStatus:  []int{http.StatusCreated, http.StatusBadRequest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url:batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			res := httptest.NewRecorder()
+
+			ShortenURLBatch(&stubURLShortener{}, validate).ServeHTTP(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("got %d, want %d", res.Code, http.StatusOK)
+			}
+
+			decoder := json.NewDecoder(res.Body)
+			var gotStatus []int
+			for decoder.More() {
+				var item model.ShortenURLBatchItemRes
+				if err := decoder.Decode(&item); err != nil {
+					t.Fatal(err)
+				}
+				if item.Index != len(gotStatus) {
+					t.Errorf("got index %d, want %d", item.Index, len(gotStatus))
+				}
+				gotStatus = append(gotStatus, item.Status)
+			}
+			if len(gotStatus) != len(tt.wantStatus) {
+				t.Fatalf("got %v statuses, want %v", gotStatus, tt.wantStatus)
+			}
+			for i := range gotStatus {
+				if gotStatus[i] != tt.wantStatus[i] {
+					t.Errorf("got %v statuses, want %v", gotStatus, tt.wantStatus)
+					break
+				}
+			}
+		})
+	}
+}
+
+// chunkRecorder records the flushes and the write deadlines of the streamed response.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	flushes   int
+	deadlines []time.Time
+}
+
+func (c *chunkRecorder) Flush() {
+	c.flushes++
+	c.ResponseRecorder.Flush()
+}
+
+func (c *chunkRecorder) SetWriteDeadline(deadline time.Time) error {
+	c.deadlines = append(c.deadlines, deadline)
+	return nil
+}
+
+func TestShortenURLBatchStreamsChunks(t *testing.T) {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	items := batchChunkSize + 1
+	body := strings.Repeat("{\"url\":\"https://www.fsf.org/blogs/community/\"}\n", items)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url:batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	res := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	shortener := &stubURLShortener{}
+
+	ShortenURLBatch(shortener, validate).ServeHTTP(res, req)
+
+	if res.flushes != 2 {
+		t.Errorf("got %d flushes, want 2", res.flushes)
+	}
+	if len(res.deadlines) != 2 || res.deadlines[1].Before(res.deadlines[0]) {
+		t.Errorf("got write deadlines %v, want one extended per chunk", res.deadlines)
+	}
+	if shortener.shortenCalls != items {
+		t.Errorf("got %d shortened URLs, want %d", shortener.shortenCalls, items)
+	}
+
+	decoder := json.NewDecoder(res.Body)
+	index := 0
+	for ; decoder.More(); index++ {
+		var item model.ShortenURLBatchItemRes
+		if err := decoder.Decode(&item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Index != index || item.Status != http.StatusCreated {
+			t.Fatalf("got item %d with status %d, want item %d created", item.Index, item.Status, index)
+		}
+	}
+	if index != items {
+		t.Errorf("got %d items, want %d", index, items)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
 	"github.com/go-playground/validator/v10"
 	"net/http"
 	"net/http/httptest"
@@ -13,16 +14,78 @@ import (
 
 type stubURLShortener struct {
 	shortenCalls int
+	shortenErr   error
+	resolveErr   error
+	manageErr    error
 }
 
-func (s *stubURLShortener) Shorten(_ context.Context, url string) (string, error) {
+func (s *stubURLShortener) Shorten(_ context.Context, shortenURLReq model.ShortenURLReq) (string, error) {
 	s.shortenCalls++
+	if s.shortenErr != nil {
+		return "", s.shortenErr
+	}
 	return "https://www.snap.it/abcd", nil
 }
 
+func (s *stubURLShortener) ShortenBatch(_ context.Context, shortenURLReqs []model.ShortenURLReq) ([]model.ShortenResult, error) {
+	results := make([]model.ShortenResult, len(shortenURLReqs))
+	for i := range shortenURLReqs {
+		s.shortenCalls++
+		results[i] = model.ShortenResult{ShortenURL: "https://www.snap.it/abcd", Err: s.shortenErr}
+	}
+	return results, nil
+}
+
 func (s *stubURLShortener) Resolve(_ context.Context, slug string) (string, error) {
-	//TODO implement me
-	panic("implement me")
+	if s.resolveErr != nil {
+		return "", s.resolveErr
+	}
+	return "https://www.fsf.org/blogs/community/i-love-free-software-2025", nil
+}
+
+func (s *stubURLShortener) List(_ context.Context, ownerId string, listReq model.ListShortenedURLsReq) (*model.ShortenedURLListRes, error) {
+	if s.manageErr != nil {
+		return nil, s.manageErr
+	}
+	return &model.ShortenedURLListRes{Items: []*model.ShortenedURLRes{}}, nil
+}
+
+func (s *stubURLShortener) Get(_ context.Context, ownerId string, slug string) (*model.ShortenedURLRes, error) {
+	if s.manageErr != nil {
+		return nil, s.manageErr
+	}
+	return &model.ShortenedURLRes{Slug: slug}, nil
+}
+
+func (s *stubURLShortener) Update(_ context.Context, ownerId string, slug string, updateReq model.UpdateShortenedURLReq) (*model.ShortenedURLRes, error) {
+	if s.manageErr != nil {
+		return nil, s.manageErr
+	}
+	return &model.ShortenedURLRes{Slug: slug}, nil
+}
+
+func (s *stubURLShortener) Delete(_ context.Context, ownerId string, slug string) error {
+	return s.manageErr
+}
+
+type stubClickTracker struct {
+	events []model.ClickEvent
+	stats  *model.ClickStatsRes
+	err    error
+}
+
+func (s *stubClickTracker) Track(event model.ClickEvent) {
+	s.events = append(s.events, event)
+}
+
+func (s *stubClickTracker) Run(_ context.Context) {}
+
+func (s *stubClickTracker) Flush(_ context.Context) error {
+	return nil
+}
+
+func (s *stubClickTracker) Stats(_ context.Context, slug string, ownerId string) (*model.ClickStatsRes, error) {
+	return s.stats, s.err
 }
 
 func TestShortenURL(t *testing.T) {
@@ -78,4 +141,155 @@ func TestShortenURL(t *testing.T) {
 			t.Errorf("got %d shortenCalls, want %d shortenCalls", shortenerStub.shortenCalls, 0)
 		}
 	})
+	t.Run("shorten with invalid slug", func(t *testing.T) {
+		shortenerStub := &stubURLShortener{
+			shortenCalls: 0,
+		}
+		shortenURLReq := &model.ShortenURLReq{
+			URL:  "https://www.fsf.org/blogs/community/i-love-free-software-2025",
+			Slug: "-q3/report",
+		}
+		payload, err := json.Marshal(shortenURLReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url", bytes.NewBuffer(payload))
+		res := httptest.NewRecorder()
+
+		ShortenURL(shortenerStub, validate).ServeHTTP(res, req)
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("got %d, want %d", res.Code, http.StatusBadRequest)
+		}
+
+		if shortenerStub.shortenCalls != 0 {
+			t.Errorf("got %d shortenCalls, want %d shortenCalls", shortenerStub.shortenCalls, 0)
+		}
+	})
+
+	t.Run("shorten with taken slug", func(t *testing.T) {
+		shortenerStub := &stubURLShortener{
+			shortenCalls: 0,
+			shortenErr:   service.ErrSlugAlreadyTaken,
+		}
+		shortenURLReq := &model.ShortenURLReq{
+			URL:  "https://www.fsf.org/blogs/community/i-love-free-software-2025",
+			Slug: "q3-report",
+		}
+		payload, err := json.Marshal(shortenURLReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url", bytes.NewBuffer(payload))
+		res := httptest.NewRecorder()
+
+		ShortenURL(shortenerStub, validate).ServeHTTP(res, req)
+
+		if res.Code != http.StatusConflict {
+			t.Errorf("got %d, want %d", res.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("shorten url with private destination", func(t *testing.T) {
+		shortenerStub := &stubURLShortener{
+			shortenErr: &service.PolicyViolation{Reason: service.ReasonPrivateAddress, Message: "The address 10.0.0.5 is private."},
+		}
+		payload, err := json.Marshal(&model.ShortenURLReq{URL: "http://10.0.0.5/admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url", bytes.NewBuffer(payload))
+		res := httptest.NewRecorder()
+
+		ShortenURL(shortenerStub, validate).ServeHTTP(res, req)
+
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Errorf("got %d, want %d", res.Code, http.StatusUnprocessableEntity)
+		}
+		var rejectionRes model.RejectionRes
+		if err = json.NewDecoder(res.Body).Decode(&rejectionRes); err != nil {
+			t.Fatal(err)
+		}
+		if rejectionRes.Reason != service.ReasonPrivateAddress {
+			t.Errorf("got reason %q, want %q", rejectionRes.Reason, service.ReasonPrivateAddress)
+		}
+	})
+
+	t.Run("shorten malicious url", func(t *testing.T) {
+		shortenerStub := &stubURLShortener{shortenErr: service.ErrMaliciousURLDetected}
+		payload, err := json.Marshal(&model.ShortenURLReq{URL: "http://malware.example/payload.exe"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shortened-url", bytes.NewBuffer(payload))
+		res := httptest.NewRecorder()
+
+		ShortenURL(shortenerStub, validate).ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotAcceptable {
+			t.Errorf("got %d, want %d", res.Code, http.StatusNotAcceptable)
+		}
+		var rejectionRes model.RejectionRes
+		if err = json.NewDecoder(res.Body).Decode(&rejectionRes); err != nil {
+			t.Fatal(err)
+		}
+		if rejectionRes.Reason != service.ReasonMaliciousURL {
+			t.Errorf("got reason %q, want %q", rejectionRes.Reason, service.ReasonMaliciousURL)
+		}
+	})
+}
+
+func TestResolve(t *testing.T) {
+	t.Run("resolve existing slug", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+		req.SetPathValue("slug", "abcd")
+		res := httptest.NewRecorder()
+		trackerStub := &stubClickTracker{}
+
+		Resolve(&stubURLShortener{}, trackerStub).ServeHTTP(res, req)
+
+		if res.Code != http.StatusFound {
+			t.Errorf("got %d, want %d", res.Code, http.StatusFound)
+		}
+
+		if len(trackerStub.events) != 1 || trackerStub.events[0].Slug != "abcd" {
+			t.Errorf("got %v tracked events, want single event for slug %q", trackerStub.events, "abcd")
+		}
+	})
+
+	t.Run("resolve expired slug", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+		res := httptest.NewRecorder()
+		trackerStub := &stubClickTracker{}
+
+		Resolve(&stubURLShortener{resolveErr: service.ErrShortenedURLExpired}, trackerStub).ServeHTTP(res, req)
+
+		if res.Code != http.StatusGone {
+			t.Errorf("got %d, want %d", res.Code, http.StatusGone)
+		}
+
+		if len(trackerStub.events) != 0 {
+			t.Errorf("got %d tracked events, want %d", len(trackerStub.events), 0)
+		}
+	})
+
+	t.Run("resolve quarantined slug", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+		res := httptest.NewRecorder()
+		trackerStub := &stubClickTracker{}
+
+		Resolve(&stubURLShortener{resolveErr: service.ErrShortenedURLQuarantined}, trackerStub).ServeHTTP(res, req)
+
+		if res.Code != http.StatusUnavailableForLegalReasons {
+			t.Errorf("got %d, want %d", res.Code, http.StatusUnavailableForLegalReasons)
+		}
+
+		if location := res.Header().Get("Location"); location != "" {
+			t.Errorf("got redirect to %q, want none", location)
+		}
+	})
 }
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// guardianScrapeTimeout bounds the Valkey calls made on every scrape.
+const guardianScrapeTimeout = 5 * time.Second
+
+// guardianCollector exposes the health of the threat providers, which is read on every scrape.
+type guardianCollector struct {
+	guardian service.URLGuardian
+
+	entries       *prometheus.Desc
+	lastUpdatedAt *prometheus.Desc
+	lastUpdateAge *prometheus.Desc
+	healthy       *prometheus.Desc
+	added         *prometheus.Desc
+	removed       *prometheus.Desc
+}
+
+// Describe sends the descriptions without collecting, as the collection queries Valkey.
+func (c *guardianCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.lastUpdatedAt
+	ch <- c.lastUpdateAge
+	ch <- c.healthy
+	ch <- c.added
+	ch <- c.removed
+}
+
+func (c *guardianCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), guardianScrapeTimeout)
+	defer cancel()
+
+	for _, health := range c.guardian.Health(ctx) {
+		ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(health.Entries), health.Name)
+		healthy := 0.0
+		if health.Healthy {
+			healthy = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.healthy, prometheus.GaugeValue, healthy, health.Name)
+		ch <- prometheus.MustNewConstMetric(c.added, prometheus.CounterValue, float64(health.TotalAdded), health.Name)
+		ch <- prometheus.MustNewConstMetric(c.removed, prometheus.CounterValue, float64(health.TotalRemoved), health.Name)
+		// The providers which have never been refreshed have no age, which is what the alerts should catch.
+		if health.LastUpdatedAt != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastUpdatedAt, prometheus.GaugeValue, float64(health.LastUpdatedAt.Unix()), health.Name)
+			ch <- prometheus.MustNewConstMetric(c.lastUpdateAge, prometheus.GaugeValue, time.Since(*health.LastUpdatedAt).Seconds(), health.Name)
+		}
+	}
+}
+
+// NewGuardianCollector creates collector of the health of the guardian's threat providers.
+func NewGuardianCollector(guardian service.URLGuardian) prometheus.Collector {
+	labels := []string{"provider"}
+
+	return &guardianCollector{
+		guardian:      guardian,
+		entries:       prometheus.NewDesc("snip_guardian_entries", "The number of URLs reported by the provider.", labels, nil),
+		lastUpdatedAt: prometheus.NewDesc("snip_guardian_last_update_timestamp_seconds", "The time of the last successful refresh of the provider.", labels, nil),
+		lastUpdateAge: prometheus.NewDesc("snip_guardian_last_update_age_seconds", "The time since the last successful refresh of the provider.", labels, nil),
+		healthy:       prometheus.NewDesc("snip_guardian_provider_healthy", "Whether the provider is healthy.", labels, nil),
+		added:         prometheus.NewDesc("snip_guardian_added_entries_total", "The number of entries added by the refreshes of the provider.", labels, nil),
+		removed:       prometheus.NewDesc("snip_guardian_removed_entries_total", "The number of entries removed by the refreshes of the provider.", labels, nil),
+	}
+}
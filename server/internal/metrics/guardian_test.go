@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+// stubURLGuardian reports the given health of the providers.
+type stubURLGuardian struct {
+	service.URLGuardian
+	healths []model.ThreatProviderHealth
+}
+
+func (g *stubURLGuardian) Health(context.Context) []model.ThreatProviderHealth {
+	return g.healths
+}
+
+func TestGuardianCollector(t *testing.T) {
+	collector := NewGuardianCollector(&stubURLGuardian{healths: []model.ThreatProviderHealth{
+		{Name: "urlhaus", TotalAdded: 120, TotalRemoved: 7},
+		{Name: "openphish", TotalAdded: 30},
+	}})
+
+	want := `
+# HELP snip_guardian_added_entries_total The number of entries added by the refreshes of the provider.
+# TYPE snip_guardian_added_entries_total counter
+snip_guardian_added_entries_total{provider="openphish"} 30
+snip_guardian_added_entries_total{provider="urlhaus"} 120
+# HELP snip_guardian_removed_entries_total The number of entries removed by the refreshes of the provider.
+# TYPE snip_guardian_removed_entries_total counter
+snip_guardian_removed_entries_total{provider="openphish"} 0
+snip_guardian_removed_entries_total{provider="urlhaus"} 7
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "snip_guardian_added_entries_total", "snip_guardian_removed_entries_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels the requests which haven't matched any route, so that arbitrary paths can't blow up the
+// cardinality of the metrics.
+const unmatchedRoute = "unmatched"
+
+// Instrument counts and times the requests per method, chi route pattern and status code.
+func Instrument(registerer prometheus.Registerer) func(http.Handler) http.Handler {
+	factory := promauto.With(registerer)
+	requests := factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "snip_http_requests_total",
+		Help: "The number of handled HTTP requests.",
+	}, []string{"method", "route", "code"})
+	durations := factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "snip_http_request_duration_seconds",
+		Help:    "The latency of the HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			// The pattern is known only after the router has matched the request.
+			route := unmatchedRoute
+			if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil && routeCtx.RoutePattern() != "" {
+				route = routeCtx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+			durations.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
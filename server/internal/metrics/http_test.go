@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	r := chi.NewRouter()
+	r.Use(Instrument(registry))
+	r.Get("/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	})
+
+	for _, target := range []string{"/abcd", "/efgh", "/abcd/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	want := `
+# HELP snip_http_requests_total The number of handled HTTP requests.
+# TYPE snip_http_requests_total counter
+snip_http_requests_total{code="302",method="GET",route="/{slug}"} 2
+snip_http_requests_total{code="404",method="GET",route="unmatched"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "snip_http_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
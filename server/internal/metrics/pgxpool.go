@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exposes the statistics of the pgx pool, which are read on every scrape.
+type poolCollector struct {
+	db *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquires             *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	canceledAcquires     *prometheus.Desc
+	emptyAcquires        *prometheus.Desc
+	newConns             *prometheus.Desc
+	maxLifetimeDestroyed *prometheus.Desc
+	maxIdleDestroyed     *prometheus.Desc
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.db.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.newConns, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeDestroyed, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleDestroyed, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+}
+
+// NewPoolCollector creates collector of the statistics of the given pool.
+func NewPoolCollector(db *pgxpool.Pool) prometheus.Collector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc("snip_pgxpool_"+name, help, nil, nil)
+	}
+
+	return &poolCollector{
+		db:                   db,
+		acquiredConns:        desc("acquired_conns", "The number of currently acquired connections."),
+		idleConns:            desc("idle_conns", "The number of currently idle connections."),
+		totalConns:           desc("total_conns", "The total number of connections in the pool."),
+		maxConns:             desc("max_conns", "The maximum size of the pool."),
+		acquires:             desc("acquires_total", "The number of successful acquires from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "The total time spent by successful acquires."),
+		canceledAcquires:     desc("canceled_acquires_total", "The number of acquires cancelled by their context."),
+		emptyAcquires:        desc("empty_acquires_total", "The number of acquires which waited for a connection."),
+		newConns:             desc("new_conns_total", "The number of new connections opened."),
+		maxLifetimeDestroyed: desc("max_lifetime_destroyed_total", "The number of connections closed due to their max lifetime."),
+		maxIdleDestroyed:     desc("max_idle_destroyed_total", "The number of connections closed due to their max idle time."),
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// urlShortener counts the outcomes of shortening and resolving by the class of their errors.
+type urlShortener struct {
+	service.URLShortener
+	outcomes *prometheus.CounterVec
+}
+
+func (s *urlShortener) Shorten(ctx context.Context, shortenURLReq model.ShortenURLReq) (string, error) {
+	shortenURL, err := s.URLShortener.Shorten(ctx, shortenURLReq)
+	s.outcomes.WithLabelValues("shorten", outcome(err)).Inc()
+
+	return shortenURL, err
+}
+
+func (s *urlShortener) ShortenBatch(ctx context.Context, shortenURLReqs []model.ShortenURLReq) ([]model.ShortenResult, error) {
+	results, err := s.URLShortener.ShortenBatch(ctx, shortenURLReqs)
+	if err != nil {
+		s.outcomes.WithLabelValues("shorten_batch", outcome(err)).Add(float64(len(shortenURLReqs)))
+		return results, err
+	}
+	for _, result := range results {
+		s.outcomes.WithLabelValues("shorten_batch", outcome(result.Err)).Inc()
+	}
+
+	return results, nil
+}
+
+func (s *urlShortener) Resolve(ctx context.Context, slug string) (string, error) {
+	url, err := s.URLShortener.Resolve(ctx, slug)
+	s.outcomes.WithLabelValues("resolve", outcome(err)).Inc()
+
+	return url, err
+}
+
+// outcome classifies the error of the shortener, the unexpected errors fall into single class.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, service.ErrMaliciousURLDetected):
+		return "malicious_url"
+	case errors.Is(err, service.ErrDestinationNotAllowed):
+		return "destination_not_allowed"
+	case errors.Is(err, service.ErrIllegalSlug):
+		return "illegal_slug"
+	case errors.Is(err, service.ErrSlugAlreadyTaken):
+		return "slug_taken"
+	case errors.Is(err, store.ErrShortenedURLNotFound):
+		return "not_found"
+	case errors.Is(err, service.ErrShortenedURLExpired):
+		return "expired"
+	case errors.Is(err, service.ErrShortenedURLDeleted):
+		return "deleted"
+	case errors.Is(err, service.ErrShortenedURLQuarantined):
+		return "quarantined"
+	default:
+		return "error"
+	}
+}
+
+// NewURLShortener decorates the shortener with the counters of the outcomes.
+func NewURLShortener(shortener service.URLShortener, registerer prometheus.Registerer) service.URLShortener {
+	return &urlShortener{
+		URLShortener: shortener,
+		outcomes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "snip_shortener_outcomes_total",
+			Help: "The number of shortened and resolved URLs by the outcome.",
+		}, []string{"operation", "outcome"}),
+	}
+}
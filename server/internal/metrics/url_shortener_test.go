@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"testing"
+)
+
+func TestOutcome(t *testing.T) {
+	tests := map[error]string{
+		service.ErrMaliciousURLDetected:  "malicious_url",
+		service.ErrIllegalSlug:           "illegal_slug",
+		store.ErrShortenedURLNotFound:    "not_found",
+		&service.PolicyViolation{}:       "destination_not_allowed",
+		fmt.Errorf("connection refused"): "error",
+	}
+	for err, want := range tests {
+		if got := outcome(fmt.Errorf("wrapped: %w", err)); got != want {
+			t.Errorf("outcome(%v) = %q, want %q", err, got, want)
+		}
+	}
+	if got := outcome(nil); got != "ok" {
+		t.Errorf("outcome(nil) = %q, want %q", got, "ok")
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valkey-io/valkey-go"
+	"strings"
+	"time"
+)
+
+// valkeyClient times the commands of the client. The dedicated connections aren't timed.
+type valkeyClient struct {
+	valkey.Client
+	durations *prometheus.HistogramVec
+}
+
+func (c *valkeyClient) Do(ctx context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+	command, start := commandName(cmd.Commands()), time.Now()
+	res := c.Client.Do(ctx, cmd)
+	c.observe(command, start)
+
+	return res
+}
+
+func (c *valkeyClient) DoMulti(ctx context.Context, multi ...valkey.Completed) []valkey.ValkeyResult {
+	if len(multi) == 0 {
+		return c.Client.DoMulti(ctx, multi...)
+	}
+	// The pipeline is labelled by its first command.
+	command, start := commandName(multi[0].Commands()), time.Now()
+	results := c.Client.DoMulti(ctx, multi...)
+	c.observe(command, start)
+
+	return results
+}
+
+func (c *valkeyClient) DoCache(ctx context.Context, cmd valkey.Cacheable, ttl time.Duration) valkey.ValkeyResult {
+	command, start := commandName(cmd.Commands()), time.Now()
+	res := c.Client.DoCache(ctx, cmd, ttl)
+	c.observe(command, start)
+
+	return res
+}
+
+func (c *valkeyClient) observe(command string, start time.Time) {
+	c.durations.WithLabelValues(command).Observe(time.Since(start).Seconds())
+}
+
+// commandName names the command before it's sent, as the client recycles the command once it's completed.
+func commandName(commands []string) string {
+	if len(commands) == 0 {
+		return "unknown"
+	}
+
+	return strings.ToLower(commands[0])
+}
+
+// NewValkeyClient decorates the client with the histogram of the command latencies.
+func NewValkeyClient(client valkey.Client, registerer prometheus.Registerer) valkey.Client {
+	return &valkeyClient{
+		Client: client,
+		durations: promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snip_valkey_command_duration_seconds",
+			Help:    "The latency of the Valkey commands and pipelines.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command"}),
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/valkey-io/valkey-go"
+	"testing"
+	"time"
+)
+
+func TestValkeyClient(t *testing.T) {
+	server := miniredis.RunT(t)
+	inner, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{server.Addr()}, DisableCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(inner.Close)
+	registry := prometheus.NewRegistry()
+	client := NewValkeyClient(inner, registry)
+	ctx := context.Background()
+
+	if err := client.Do(ctx, client.B().Set().Key("abc").Value("https://www.fsf.org/").Build()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range client.DoMulti(ctx, client.B().Get().Key("abc").Build(), client.B().Set().Key("def").Value("").Build()) {
+		if err := res.Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.DoCache(ctx, client.B().Get().Key("abc").Cache(), time.Minute).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]uint64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			got[metric.GetLabel()[0].GetValue()] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+	// The pipeline is labelled by its first command.
+	if len(got) != 2 || got["set"] != 1 || got["get"] != 2 {
+		t.Errorf("got %v observations, want 1 set and 2 get", got)
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+type APIKey struct {
+	Id        int64
+	OwnerId   string
+	Name      string
+	KeyHash   string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
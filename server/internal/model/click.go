@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+type ClickEvent struct {
+	Slug       string
+	Referrer   string
+	UserAgent  string
+	ClientIP   string
+	OccurredAt time.Time
+}
+
+type DailyClicks struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+type ClickStatsRes struct {
+	Slug        string           `json:"slug"`
+	TotalClicks int64            `json:"totalClicks"`
+	Daily       []DailyClicks    `json:"daily"`
+	Referrers   []ReferrerClicks `json:"referrers"`
+}
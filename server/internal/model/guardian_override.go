@@ -0,0 +1,88 @@
+package model
+
+import (
+	"context"
+	"github.com/go-playground/validator/v10"
+	"time"
+)
+
+// The kinds of the guardian overrides.
+const (
+	OverrideAllow = "allow"
+	OverrideDeny  = "deny"
+)
+
+// The granularities at which the guardian overrides match URLs.
+const (
+	OverrideExact      = "exact"
+	OverridePathPrefix = "path_prefix"
+	OverrideHost       = "host"
+	OverrideDomain     = "domain"
+)
+
+// GuardianOverride allows URLs reported by the feeds or denies URLs which the feeds don't report.
+type GuardianOverride struct {
+	Id          int64  `json:"id"`
+	Kind        string `json:"kind"`
+	Granularity string `json:"granularity"`
+	// Pattern is the match key of the URL at the granularity of the override.
+	Pattern   string     `json:"pattern"`
+	URL       string     `json:"url"`
+	Reason    string     `json:"reason"`
+	Author    string     `json:"author"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Expired reports whether the override has expired at the given moment.
+func (o *GuardianOverride) Expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !o.ExpiresAt.After(now)
+}
+
+type CreateGuardianOverrideReq struct {
+	Kind string `json:"kind" validate:"required,oneof=allow deny"`
+	URL  string `json:"url" validate:"required,max=4096,http_url"`
+	// Granularity defaults to exact.
+	Granularity string     `json:"granularity,omitempty" validate:"omitempty,oneof=exact path_prefix host domain"`
+	Reason      string     `json:"reason" validate:"required,max=1024"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	// TTL holds Go duration string e.g. "72h" after which the override expires.
+	TTL string `json:"ttl,omitempty" validate:"excluded_with=ExpiresAt"`
+}
+
+// ExpirationTime returns the moment at which the override expires or nil when it never expires.
+func (c CreateGuardianOverrideReq) ExpirationTime(now time.Time) *time.Time {
+	return ShortenURLReq{ExpiresAt: c.ExpiresAt, TTL: c.TTL}.ExpirationTime(now)
+}
+
+func (c CreateGuardianOverrideReq) Validate(ctx context.Context, validate *validator.Validate) map[string]string {
+	problems := structProblems(ctx, validate, c)
+
+	if len(problems) == 0 {
+		expirationProblems(c.ExpiresAt, c.TTL, problems)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+type GuardianOverrideListRes struct {
+	Items []*GuardianOverride `json:"items"`
+}
+
+// GuardianOverrideAudit records single change of the guardian overrides.
+type GuardianOverrideAudit struct {
+	Id         int64            `json:"id"`
+	OverrideId int64            `json:"overrideId"`
+	Action     string           `json:"action"`
+	Author     string           `json:"author"`
+	Override   GuardianOverride `json:"override"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+type GuardianOverrideAuditListRes struct {
+	Items []*GuardianOverrideAudit `json:"items"`
+}
@@ -0,0 +1,19 @@
+package model
+
+type DependencyHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Degradable dependencies degrade the replica instead of making it unready when they are unhealthy.
+	Degradable bool `json:"degradable,omitempty"`
+	// LatencyMs is how long the check took in milliseconds.
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ReadinessRes struct {
+	Ready    bool `json:"ready"`
+	Draining bool `json:"draining,omitempty"`
+	// Degraded replicas are ready, but some of their degradable dependencies are unhealthy.
+	Degraded     bool               `json:"degraded,omitempty"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
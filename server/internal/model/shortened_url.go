@@ -5,18 +5,105 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
+	"regexp"
 	"time"
 )
 
+var slugRegexp = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z_-]*$`)
+
+// reservedSlugs holds slugs which clash with paths served by snip or by the client.
+var reservedSlugs = map[string]struct{}{
+	"api":         {},
+	"index.html":  {},
+	"favicon.ico": {},
+	"js":          {},
+}
+
+// ValidSlug reports whether the given slug is composed only of characters allowed in slugs.
+func ValidSlug(slug string) bool {
+	return len(slug) <= 64 && slugRegexp.MatchString(slug)
+}
+
 type Validatable interface {
 	Validate(ctx context.Context, validate *validator.Validate) map[string]string
 }
 
 type ShortenURLReq struct {
-	URL string `json:"url" validate:"required,min=16,max=4096,http_url"`
+	URL       string     `json:"url" validate:"required,min=16,max=4096,http_url"`
+	Slug      string     `json:"slug,omitempty" validate:"omitempty,min=3,max=64"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// TTL holds Go duration string e.g. "72h" after which the shortened URL expires.
+	TTL string `json:"ttl,omitempty" validate:"excluded_with=ExpiresAt"`
+	// Deduplicate requests reusing the shortened URL of an identical, previously deduplicated URL.
+	Deduplicate bool `json:"deduplicate,omitempty" validate:"excluded_with=Slug ExpiresAt TTL"`
+	// OwnerId is set from the authenticated API key, never from the request body.
+	OwnerId string `json:"-"`
+}
+
+// ExpirationTime returns the moment at which the shortened URL expires or nil when it never expires.
+func (s ShortenURLReq) ExpirationTime(now time.Time) *time.Time {
+	if s.ExpiresAt != nil {
+		expiresAt := s.ExpiresAt.UTC()
+		return &expiresAt
+	}
+	if ttl, err := time.ParseDuration(s.TTL); err == nil && s.TTL != "" {
+		expiresAt := now.Add(ttl).UTC()
+		return &expiresAt
+	}
+
+	return nil
 }
 
 func (s ShortenURLReq) Validate(ctx context.Context, validate *validator.Validate) map[string]string {
+	problems := structProblems(ctx, validate, s)
+
+	if len(problems) == 0 && s.Slug != "" {
+		if !ValidSlug(s.Slug) {
+			problems["slug"] = "The 'slug' may contain only letters, digits, '-' and '_' and must start with a letter or digit."
+		} else if _, reserved := reservedSlugs[s.Slug]; reserved {
+			problems["slug"] = fmt.Sprintf("The 'slug' value '%s' is reserved.", s.Slug)
+		}
+	}
+
+	if len(problems) == 0 {
+		expirationProblems(s.ExpiresAt, s.TTL, problems)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+type UpdateShortenedURLReq struct {
+	URL       *string    `json:"url,omitempty" validate:"omitempty,min=16,max=4096,http_url"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// TTL holds Go duration string e.g. "72h" after which the shortened URL expires, counting from the update.
+	TTL string `json:"ttl,omitempty" validate:"excluded_with=ExpiresAt"`
+}
+
+// ExpirationTime returns the new expiration time or nil when the update keeps the current one.
+func (u UpdateShortenedURLReq) ExpirationTime(now time.Time) *time.Time {
+	return ShortenURLReq{ExpiresAt: u.ExpiresAt, TTL: u.TTL}.ExpirationTime(now)
+}
+
+func (u UpdateShortenedURLReq) Validate(ctx context.Context, validate *validator.Validate) map[string]string {
+	problems := structProblems(ctx, validate, u)
+
+	if len(problems) == 0 {
+		expirationProblems(u.ExpiresAt, u.TTL, problems)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+func structProblems(ctx context.Context, validate *validator.Validate, s any) map[string]string {
+	problems := map[string]string{}
 	err := validate.StructCtx(ctx, s)
 	if err != nil {
 		// this check is only needed when the code could produce an invalid value
@@ -26,16 +113,24 @@ func (s ShortenURLReq) Validate(ctx context.Context, validate *validator.Validat
 			panic(err)
 		}
 
-		problems := map[string]string{}
 		for _, fieldError := range err.(validator.ValidationErrors) {
 			problems[fieldError.Field()] = errMessage(fieldError)
 		}
-
-		return problems
 	}
 
-	return nil
+	return problems
+}
 
+func expirationProblems(expiresAt *time.Time, ttl string, problems map[string]string) {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		problems["expiresAt"] = "The 'expiresAt' must be in the future."
+	}
+
+	if ttl != "" {
+		if d, err := time.ParseDuration(ttl); err != nil || d <= 0 {
+			problems["ttl"] = "The 'ttl' must be positive duration e.g. 30m or 72h."
+		}
+	}
 }
 
 func errMessage(err validator.FieldError) string {
@@ -55,6 +150,10 @@ func errMessage(err validator.FieldError) string {
 		message = fmt.Sprintf("The '%s' must be less than or equal to %s.", err.Field(), err.Param())
 	case "http_url":
 		message = fmt.Sprintf("The '%s' must be valid http(s) URL.", err.Field())
+	case "oneof":
+		message = fmt.Sprintf("The '%s' must be one of: %s.", err.Field(), err.Param())
+	case "excluded_with":
+		message = fmt.Sprintf("The '%s' cannot be combined with '%s'.", err.Field(), err.Param())
 	default:
 		message = err.Error() // Fallback to default message
 	}
@@ -67,9 +166,84 @@ type ShortenURLRes struct {
 	ShortenURL string `json:"shortenURL"`
 }
 
+type ShortenResult struct {
+	ShortenURL string
+	Err        error
+}
+
+// ShortenURLBatchItemRes is the result of shortening single URL of a batch.
+type ShortenURLBatchItemRes struct {
+	Index      int               `json:"index"`
+	Status     int               `json:"status"`
+	ShortenURL string            `json:"shortenURL,omitempty"`
+	Problems   map[string]string `json:"problems,omitempty"`
+	Rejection  *RejectionRes     `json:"rejection,omitempty"`
+}
+
+// RejectionRes explains why the URL cannot be shortened.
+type RejectionRes struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
 type ShortenedURL struct {
 	Id          int64
 	Slug        string
 	OriginalURL string
 	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	// URLHash holds the hash of the normalized original URL of deduplicated shortened URLs.
+	URLHash   string
+	OwnerId   string
+	DeletedAt *time.Time
+	// QuarantinedAt is set when the original URL has been reported as malicious after the URL was shortened.
+	QuarantinedAt *time.Time
+}
+
+// Deleted reports whether the shortened URL has been deleted by its owner.
+func (s *ShortenedURL) Deleted() bool {
+	return s.DeletedAt != nil
+}
+
+// Quarantined reports whether the original URL has been reported as malicious since the URL was shortened.
+func (s *ShortenedURL) Quarantined() bool {
+	return s.QuarantinedAt != nil
+}
+
+// Expired reports whether the shortened URL has expired at the given moment.
+func (s *ShortenedURL) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !s.ExpiresAt.After(now)
+}
+
+type ShortenedURLRes struct {
+	Slug       string     `json:"slug"`
+	ShortenURL string     `json:"shortenURL"`
+	URL        string     `json:"url"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	// Quarantined shortened URLs don't redirect until their URL is updated.
+	Quarantined bool `json:"quarantined,omitempty"`
+}
+
+type ShortenedURLListRes struct {
+	Items []*ShortenedURLRes `json:"items"`
+	// NextCursor is passed as the cursor of the request for the next page, it's empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type ListShortenedURLsReq struct {
+	Cursor        string
+	Limit         int
+	URLContains   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+type ShortenedURLFilter struct {
+	OwnerId       string
+	BeforeId      int64
+	URLContains   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
 }
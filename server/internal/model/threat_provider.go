@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+type ThreatProviderHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Entries int64  `json:"entries"`
+	// LastAdded and LastRemoved count the entries changed by the last refresh of this replica.
+	LastAdded   int64 `json:"lastAdded"`
+	LastRemoved int64 `json:"lastRemoved"`
+	// TotalAdded and TotalRemoved count the entries changed by all the refreshes of this replica.
+	TotalAdded    int64      `json:"totalAdded"`
+	TotalRemoved  int64      `json:"totalRemoved"`
+	LastUpdatedAt *time.Time `json:"lastUpdatedAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"strings"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+var ErrNotOwner = errors.New("the shortened URL is owned by someone else")
+
+const apiKeyPrefix = "snip_"
+
+type APIKeyAuthenticator interface {
+	// Authenticate returns the owner of the given API key.
+	Authenticate(ctx context.Context, key string) (string, error)
+	// Create issues new API key for the given owner. The returned key is never stored and cannot be recovered.
+	Create(ctx context.Context, ownerId string, name string) (string, error)
+}
+
+type apiKeyAuthenticator struct {
+	store store.APIKey
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, key string) (string, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", ErrInvalidAPIKey
+	}
+
+	apiKey, err := a.store.FindByKeyHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, store.ErrAPIKeyNotFound) {
+			return "", ErrInvalidAPIKey
+		}
+		return "", err
+	}
+
+	if apiKey.RevokedAt != nil {
+		return "", ErrInvalidAPIKey
+	}
+
+	return apiKey.OwnerId, nil
+}
+
+func (a *apiKeyAuthenticator) Create(ctx context.Context, ownerId string, name string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &model.APIKey{
+		OwnerId: ownerId,
+		Name:    name,
+		KeyHash: hashAPIKey(key),
+	}
+	if err := a.store.Save(ctx, apiKey); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// hashAPIKey uses plain SHA-256, which is sufficient for randomly generated keys with 256 bits of entropy.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewAPIKeyAuthenticator(store store.APIKey) APIKeyAuthenticator {
+	return &apiKeyAuthenticator{store: store}
+}
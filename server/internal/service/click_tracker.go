@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"log/slog"
+	"time"
+)
+
+const clickEventsBufferSize = 10_000
+const clickEventsPublishBatchSize = 500
+const clickEventsFlushBatchSize = 5_000
+const clickStatsDays = 30
+
+// ClickTracker records redirects without making the caller wait on Valkey or Postgres.
+// Tracked events are buffered in-process, published to a Valkey stream by Run and aggregated into Postgres by Flush.
+type ClickTracker interface {
+	Track(event model.ClickEvent)
+	Run(ctx context.Context)
+	Flush(ctx context.Context) error
+	// Stats returns click statistics of the given slug. Stats of owned shortened URLs are available only to their owners.
+	Stats(ctx context.Context, slug string, ownerId string) (*model.ClickStatsRes, error)
+}
+
+type clickTracker struct {
+	events   chan model.ClickEvent
+	consumer string
+	stream   store.ClickEventStream
+	clicks   store.URLClick
+	store    store.ShortenedURL
+	logger   *slog.Logger
+}
+
+func (t *clickTracker) Track(event model.ClickEvent) {
+	select {
+	case t.events <- event:
+	default:
+		t.logger.Warn("The click events buffer is full - dropping event.", "slug", event.Slug)
+	}
+}
+
+func (t *clickTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	batch := make([]model.ClickEvent, 0, clickEventsPublishBatchSize)
+	publish := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.stream.Publish(ctx, batch); err != nil {
+			t.logger.ErrorContext(ctx, fmt.Sprintf("Error while publishing %d click events.", len(batch)), "err", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Publish whatever is left in the buffer before giving up.
+			drainCtx, drainCtxRelease := context.WithTimeout(context.Background(), 5*time.Second)
+			for len(t.events) > 0 {
+				batch = append(batch, <-t.events)
+				if len(batch) == clickEventsPublishBatchSize {
+					publish(drainCtx)
+				}
+			}
+			publish(drainCtx)
+			drainCtxRelease()
+			return
+		case event := <-t.events:
+			batch = append(batch, event)
+			if len(batch) == clickEventsPublishBatchSize {
+				publish(ctx)
+			}
+		case <-ticker.C:
+			publish(ctx)
+		}
+	}
+}
+
+func (t *clickTracker) Flush(ctx context.Context) error {
+	for {
+		events, ids, err := t.stream.Consume(ctx, t.consumer, clickEventsFlushBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if err = t.clicks.Record(ctx, events); err != nil {
+			return err
+		}
+
+		if err = t.stream.Ack(ctx, ids); err != nil {
+			return err
+		}
+		t.logger.DebugContext(ctx, fmt.Sprintf("Flushed %d click events.", len(events)))
+
+		if len(ids) < clickEventsFlushBatchSize {
+			return nil
+		}
+	}
+}
+
+func (t *clickTracker) Stats(ctx context.Context, slug string, ownerId string) (*model.ClickStatsRes, error) {
+	if !model.ValidSlug(slug) {
+		return nil, ErrIllegalSlug
+	}
+
+	shortenedURL, err := t.store.FindBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if shortenedURL.OwnerId != "" && shortenedURL.OwnerId != ownerId {
+		return nil, ErrNotOwner
+	}
+
+	return t.clicks.Stats(ctx, slug, clickStatsDays)
+}
+
+func NewClickTracker(consumer string, stream store.ClickEventStream, clicks store.URLClick, store store.ShortenedURL, logger *slog.Logger) ClickTracker {
+	return &clickTracker{
+		events:   make(chan model.ClickEvent, clickEventsBufferSize),
+		consumer: consumer,
+		stream:   stream,
+		clicks:   clicks,
+		store:    store,
+		logger:   logger,
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// stubURLClick records the click events unless it has been told to fail.
+type stubURLClick struct {
+	store.URLClick
+	events []model.ClickEvent
+	err    error
+}
+
+func (s *stubURLClick) Record(_ context.Context, events []model.ClickEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func TestClickTrackerFlush(t *testing.T) {
+	_, client := newTestValkey(t)
+	clicks := &stubURLClick{}
+	tracker := NewClickTracker("snip-1", store.NewClickEventStream(client, time.Minute), clicks, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+
+	occurredAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, slug := range []string{"abc", "def", "abc"} {
+		tracker.Track(model.ClickEvent{Slug: slug, OccurredAt: occurredAt})
+	}
+	// The tracker publishes the buffered events once stopped.
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	tracker.Run(runCtx)
+
+	errDatabaseDown := errors.New("database down")
+	clicks.err = errDatabaseDown
+	if err := tracker.Flush(ctx); !errors.Is(err, errDatabaseDown) {
+		t.Fatalf("got %v, want %v", err, errDatabaseDown)
+	}
+
+	// The events which failed to be recorded are still pending and recorded by the next flush.
+	clicks.err = nil
+	if err := tracker.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(clicks.events) != 3 || clicks.events[0].Slug != "abc" || clicks.events[1].Slug != "def" {
+		t.Errorf("got %+v, want the tracked events in order", clicks.events)
+	}
+
+	if err := tracker.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(clicks.events) != 3 {
+		t.Errorf("got %d events, want the acknowledged events recorded once", len(clicks.events))
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrDestinationNotAllowed = errors.New("the destination of the URL is not allowed")
+
+// The reasons why the destination policy refuses URLs.
+const (
+	ReasonMaliciousURL     = "malicious_url"
+	ReasonPrivateAddress   = "private_address"
+	ReasonLoopbackAddress  = "loopback_address"
+	ReasonLinkLocalAddress = "link_local_address"
+	ReasonMetadataAddress  = "metadata_address"
+	ReasonInternalHost     = "internal_host"
+	ReasonUnresolvableHost = "unresolvable_host"
+	ReasonDeniedDomain     = "denied_domain"
+	ReasonDomainNotAllowed = "domain_not_allowed"
+	ReasonDeniedPort       = "denied_port"
+	ReasonPortNotAllowed   = "port_not_allowed"
+)
+
+// PolicyViolation describes why the destination of the URL is not allowed.
+type PolicyViolation struct {
+	Reason  string
+	Message string
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s", ErrDestinationNotAllowed, v.Message)
+}
+
+func (v *PolicyViolation) Unwrap() error {
+	return ErrDestinationNotAllowed
+}
+
+// DestinationPolicy decides where the shortened URLs may lead to.
+type DestinationPolicy interface {
+	// Check returns *PolicyViolation when the destination of the URL is not allowed.
+	Check(ctx context.Context, rawURL string) error
+}
+
+// DestinationPolicyConfig lists the domains and ports the shortened URLs may or may not lead to. The domains match
+// their subdomains as well. Empty allow lists allow everything which isn't denied.
+type DestinationPolicyConfig struct {
+	AllowedDomains []string
+	DeniedDomains  []string
+	AllowedPorts   []int
+	DeniedPorts    []int
+}
+
+// hostResolver is satisfied by net.Resolver.
+type hostResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+type destinationPolicy struct {
+	config   DestinationPolicyConfig
+	resolver hostResolver
+}
+
+// metadataAddresses are the addresses of the instance metadata services of the cloud providers.
+var metadataAddresses = []netip.Addr{
+	netip.MustParseAddr("169.254.169.254"),
+	netip.MustParseAddr("169.254.170.2"),
+	netip.MustParseAddr("100.100.100.200"),
+	netip.MustParseAddr("fd00:ec2::254"),
+}
+
+// internalHostSuffixes are the suffixes of hostnames which are resolvable only within private networks.
+var internalHostSuffixes = []string{".localhost", ".local", ".internal", ".intranet", ".corp", ".home.arpa", ".lan"}
+
+// sharedAddressSpace is the carrier-grade NAT range, which isn't covered by netip.Addr.IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func (p *destinationPolicy) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if portNumber, err := strconv.Atoi(port); err == nil {
+		if slices.Contains(p.config.DeniedPorts, portNumber) {
+			return &PolicyViolation{Reason: ReasonDeniedPort, Message: fmt.Sprintf("The port %d is denied.", portNumber)}
+		}
+		if len(p.config.AllowedPorts) > 0 && !slices.Contains(p.config.AllowedPorts, portNumber) {
+			return &PolicyViolation{Reason: ReasonPortNotAllowed, Message: fmt.Sprintf("The port %d is not allowed.", portNumber)}
+		}
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if violation := addressViolation(addr); violation != nil {
+			return violation
+		}
+		// The address literal has no domain, so it can never match the allow-list.
+		if len(p.config.AllowedDomains) > 0 {
+			return &PolicyViolation{Reason: ReasonDomainNotAllowed, Message: fmt.Sprintf("The address %s is not allowed.", addr)}
+		}
+		return nil
+	}
+
+	if matchesDomain(host, p.config.DeniedDomains) {
+		return &PolicyViolation{Reason: ReasonDeniedDomain, Message: fmt.Sprintf("The domain of %s is denied.", host)}
+	}
+	if len(p.config.AllowedDomains) > 0 && !matchesDomain(host, p.config.AllowedDomains) {
+		return &PolicyViolation{Reason: ReasonDomainNotAllowed, Message: fmt.Sprintf("The domain of %s is not allowed.", host)}
+	}
+	if internalHost(host) {
+		return &PolicyViolation{Reason: ReasonInternalHost, Message: fmt.Sprintf("The host %s is internal.", host)}
+	}
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return &PolicyViolation{Reason: ReasonUnresolvableHost, Message: fmt.Sprintf("The host %s cannot be resolved.", host)}
+	}
+	// The host is refused when any of its addresses is, as any of them may be the one eventually connected to.
+	for _, addr := range addrs {
+		if violation := addressViolation(addr); violation != nil {
+			return violation
+		}
+	}
+
+	return nil
+}
+
+// addressViolation returns *PolicyViolation when the address isn't publicly routable.
+func addressViolation(addr netip.Addr) error {
+	addr = addr.Unmap()
+	switch {
+	case slices.Contains(metadataAddresses, addr):
+		return &PolicyViolation{Reason: ReasonMetadataAddress, Message: fmt.Sprintf("The address %s belongs to metadata service.", addr)}
+	case addr.IsLoopback() || addr.IsUnspecified():
+		return &PolicyViolation{Reason: ReasonLoopbackAddress, Message: fmt.Sprintf("The address %s is loopback.", addr)}
+	case addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast():
+		return &PolicyViolation{Reason: ReasonLinkLocalAddress, Message: fmt.Sprintf("The address %s is link-local.", addr)}
+	case addr.IsPrivate() || addr.IsMulticast() || sharedAddressSpace.Contains(addr):
+		return &PolicyViolation{Reason: ReasonPrivateAddress, Message: fmt.Sprintf("The address %s is private.", addr)}
+	}
+
+	return nil
+}
+
+// internalHost reports whether the host is single label or has any of the internal suffixes.
+func internalHost(host string) bool {
+	if !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesDomain reports whether the host is any of the domains or their subdomains.
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.Trim(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewDestinationPolicy(config DestinationPolicyConfig) DestinationPolicy {
+	return &destinationPolicy{
+		config:   config,
+		resolver: net.DefaultResolver,
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+type stubHostResolver map[string][]netip.Addr
+
+func (r stubHostResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	return addrs, nil
+}
+
+func TestDestinationPolicy(t *testing.T) {
+	policy := &destinationPolicy{
+		config: DestinationPolicyConfig{
+			DeniedDomains: []string{"evil.example"},
+			DeniedPorts:   []int{22},
+		},
+		resolver: stubHostResolver{
+			"www.fsf.org":       {netip.MustParseAddr("209.51.188.174")},
+			"intranet.fsf.org":  {netip.MustParseAddr("209.51.188.174"), netip.MustParseAddr("192.168.1.10")},
+			"rebind.fsf.org":    {netip.MustParseAddr("::ffff:127.0.0.1")},
+			"www.evil.example":  {netip.MustParseAddr("203.0.113.1")},
+			"metadata.fsf.org":  {netip.MustParseAddr("169.254.169.254")},
+			"link-local.fsf.io": {netip.MustParseAddr("fe80::1")},
+		},
+	}
+
+	tests := map[string]string{
+		"https://www.fsf.org/blogs":              "",
+		"http://10.0.0.5/admin":                  ReasonPrivateAddress,
+		"http://localhost:8081/api/v1/healthz":   ReasonInternalHost,
+		"http://[::1]:8081/":                     ReasonLoopbackAddress,
+		"http://169.254.169.254/latest/":         ReasonMetadataAddress,
+		"http://metadata.fsf.org/":               ReasonMetadataAddress,
+		"http://link-local.fsf.io/":              ReasonLinkLocalAddress,
+		"https://intranet.fsf.org/":              ReasonPrivateAddress,
+		"https://rebind.fsf.org/":                ReasonLoopbackAddress,
+		"https://metadata.google.internal/":      ReasonInternalHost,
+		"https://www.evil.example/":              ReasonDeniedDomain,
+		"https://www.fsf.org:22/":                ReasonDeniedPort,
+		"https://unknown.fsf.org/":               ReasonUnresolvableHost,
+		"http://100.64.0.1/":                     ReasonPrivateAddress,
+		"https://www.fsf.org:443/blogs?ref=snip": "",
+	}
+	for rawURL, want := range tests {
+		err := policy.Check(context.Background(), rawURL)
+		var violation *PolicyViolation
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("Check(%q) returned %v, want nil", rawURL, err)
+		case want != "" && !errors.As(err, &violation):
+			t.Errorf("Check(%q) returned %v, want %s violation", rawURL, err, want)
+		case want != "" && violation.Reason != want:
+			t.Errorf("Check(%q) returned %s violation, want %s", rawURL, violation.Reason, want)
+		}
+	}
+
+	allowList := &destinationPolicy{
+		config:   DestinationPolicyConfig{AllowedDomains: []string{"fsf.org"}, AllowedPorts: []int{443}},
+		resolver: policy.resolver,
+	}
+	for rawURL, want := range map[string]string{
+		"https://www.fsf.org/":      "",
+		"https://www.evil.example/": ReasonDomainNotAllowed,
+		"http://www.fsf.org/":       ReasonPortNotAllowed,
+		"https://203.0.113.7/login": ReasonDomainNotAllowed,
+		"https://10.0.0.5/login":    ReasonPrivateAddress,
+	} {
+		err := allowList.Check(context.Background(), rawURL)
+		var violation *PolicyViolation
+		if want == "" && err != nil || want != "" && (!errors.As(err, &violation) || violation.Reason != want) {
+			t.Errorf("Check(%q) returned %v, want %q", rawURL, err, want)
+		}
+	}
+}
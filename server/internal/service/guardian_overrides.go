@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrOverrideNotApplicable = errors.New("the URL has nothing to match at the granularity of the override")
+
+const defaultAuditLimit = 100
+const maxAuditLimit = 1000
+
+// OverrideVerdict is the decision of the manual overrides about URL.
+type OverrideVerdict int
+
+const (
+	// VerdictNone leaves the decision to the threat feeds.
+	VerdictNone OverrideVerdict = iota
+	// VerdictAllow considers the URL safe even when the threat feeds report it.
+	VerdictAllow
+	// VerdictDeny considers the URL malicious even when none of the threat feeds reports it.
+	VerdictDeny
+)
+
+// overrideGranularities maps the granularities of the overrides to the granularities of the match keys.
+var overrideGranularities = map[string]granularity{
+	model.OverrideExact:      granularityExact,
+	model.OverridePathPrefix: granularityPathPrefix,
+	model.OverrideHost:       granularityHost,
+	model.OverrideDomain:     granularityDomain,
+}
+
+// GuardianOverrides administers the allowlist and the denylist of the guardian.
+type GuardianOverrides interface {
+	List(ctx context.Context) ([]*model.GuardianOverride, error)
+	// Create overrides the URL at the requested granularity on behalf of the author.
+	Create(ctx context.Context, author string, createReq model.CreateGuardianOverrideReq) (*model.GuardianOverride, error)
+	Delete(ctx context.Context, author string, id int64) error
+	// Audit returns up to limit latest changes of the overrides.
+	Audit(ctx context.Context, limit int) ([]*model.GuardianOverrideAudit, error)
+	// Verdicts returns the verdict of the overrides for each of the URLs. Deny wins when the URL matches both lists.
+	Verdicts(ctx context.Context, urls []string) ([]OverrideVerdict, error)
+}
+
+// overrideKey identifies the override by what it matches.
+type overrideKey struct {
+	kind        string
+	granularity granularity
+	pattern     string
+}
+
+// guardianOverrides keeps the active overrides in memory, reloading them every reloadInterval so that the changes
+// made on other replicas are picked up as well.
+type guardianOverrides struct {
+	store          store.GuardianOverride
+	reloadInterval time.Duration
+	logger         *slog.Logger
+
+	mu        sync.Mutex
+	overrides map[overrideKey]*model.GuardianOverride
+	loadedAt  time.Time
+}
+
+func (o *guardianOverrides) List(ctx context.Context) ([]*model.GuardianOverride, error) {
+	return o.store.ListActive(ctx)
+}
+
+func (o *guardianOverrides) Create(ctx context.Context, author string, createReq model.CreateGuardianOverrideReq) (*model.GuardianOverride, error) {
+	g := createReq.Granularity
+	if g == "" {
+		g = model.OverrideExact
+	}
+	pattern, err := overridePattern(createReq.URL, overrideGranularities[g])
+	if err != nil {
+		return nil, err
+	}
+
+	override := &model.GuardianOverride{
+		Kind:        createReq.Kind,
+		Granularity: g,
+		Pattern:     pattern,
+		URL:         createReq.URL,
+		Reason:      createReq.Reason,
+		Author:      author,
+		ExpiresAt:   createReq.ExpirationTime(time.Now()),
+	}
+	if err = o.store.Save(ctx, override); err != nil {
+		return nil, err
+	}
+	o.logger.InfoContext(ctx, fmt.Sprintf("Created %s override of %s at %s granularity.", override.Kind, override.Pattern, override.Granularity), "author", author)
+	o.invalidate()
+
+	return override, nil
+}
+
+func (o *guardianOverrides) Delete(ctx context.Context, author string, id int64) error {
+	override, err := o.store.Delete(ctx, id, author)
+	if err != nil {
+		return err
+	}
+	o.logger.InfoContext(ctx, fmt.Sprintf("Deleted %s override of %s at %s granularity.", override.Kind, override.Pattern, override.Granularity), "author", author)
+	o.invalidate()
+
+	return nil
+}
+
+func (o *guardianOverrides) Audit(ctx context.Context, limit int) ([]*model.GuardianOverrideAudit, error) {
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	return o.store.ListAudit(ctx, min(limit, maxAuditLimit))
+}
+
+func (o *guardianOverrides) Verdicts(ctx context.Context, urls []string) ([]OverrideVerdict, error) {
+	verdicts := make([]OverrideVerdict, len(urls))
+	overrides, err := o.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) == 0 {
+		return verdicts, nil
+	}
+
+	now := time.Now()
+	matches := func(kind string, g granularity, pattern string) bool {
+		override, ok := overrides[overrideKey{kind: kind, granularity: g, pattern: pattern}]
+		// The expiration is checked here as well, as the overrides may expire between the reloads.
+		return ok && !override.Expired(now)
+	}
+	for i, url := range urls {
+		candidates := map[granularity][]string{granularityExact: {url}}
+		if keys, err := matchKeys(url); err == nil {
+			candidates = map[granularity][]string{
+				granularityExact:      keys.exactKeys(),
+				granularityPathPrefix: keys.pathPrefixes,
+				granularityHost:       {keys.host},
+				granularityDomain:     {keys.domain},
+			}
+		}
+		for g, patterns := range candidates {
+			for _, pattern := range patterns {
+				switch {
+				case matches(model.OverrideDeny, g, pattern):
+					verdicts[i] = VerdictDeny
+				case matches(model.OverrideAllow, g, pattern) && verdicts[i] == VerdictNone:
+					verdicts[i] = VerdictAllow
+				}
+			}
+		}
+	}
+
+	return verdicts, nil
+}
+
+// current returns the active overrides, reloading them when they are older than the reload interval. Failed reload
+// falls back to the previously loaded overrides.
+func (o *guardianOverrides) current(ctx context.Context) (map[overrideKey]*model.GuardianOverride, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.overrides != nil && time.Since(o.loadedAt) < o.reloadInterval {
+		return o.overrides, nil
+	}
+
+	active, err := o.store.ListActive(ctx)
+	if err != nil {
+		if o.overrides != nil {
+			o.logger.WarnContext(ctx, "Error while reloading the guardian overrides, keeping the previous ones.", "err", err)
+			return o.overrides, nil
+		}
+		return nil, err
+	}
+
+	overrides := make(map[overrideKey]*model.GuardianOverride, len(active))
+	for _, override := range active {
+		key := overrideKey{kind: override.Kind, granularity: overrideGranularities[override.Granularity], pattern: override.Pattern}
+		overrides[key] = override
+	}
+	o.overrides, o.loadedAt = overrides, time.Now()
+
+	return overrides, nil
+}
+
+// invalidate makes the next verdict reload the overrides, so that the changes take effect on this replica at once.
+func (o *guardianOverrides) invalidate() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.loadedAt = time.Time{}
+}
+
+// overridePattern returns the match key of the URL at the given granularity. The path prefix is the innermost
+// directory of the URL.
+func overridePattern(rawURL string, g granularity) (string, error) {
+	keys, err := matchKeys(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch g {
+	case granularityPathPrefix:
+		if len(keys.pathPrefixes) == 0 {
+			return "", fmt.Errorf("%w: the path of %s has no directory", ErrOverrideNotApplicable, rawURL)
+		}
+		return keys.pathPrefixes[len(keys.pathPrefixes)-1], nil
+	case granularityHost:
+		return keys.host, nil
+	case granularityDomain:
+		if keys.domain == "" {
+			return "", fmt.Errorf("%w: the host of %s has no registrable domain", ErrOverrideNotApplicable, rawURL)
+		}
+		return keys.domain, nil
+	default:
+		return keys.exact, nil
+	}
+}
+
+// NewGuardianOverrides creates the overrides, which are reloaded from the store every reloadInterval.
+func NewGuardianOverrides(store store.GuardianOverride, reloadInterval time.Duration, logger *slog.Logger) GuardianOverrides {
+	return &guardianOverrides{
+		store:          store,
+		reloadInterval: reloadInterval,
+		logger:         logger,
+	}
+}
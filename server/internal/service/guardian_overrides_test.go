@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type stubGuardianOverrideStore struct {
+	overrides []*model.GuardianOverride
+	loads     int
+}
+
+func (s *stubGuardianOverrideStore) ListActive(context.Context) ([]*model.GuardianOverride, error) {
+	s.loads++
+	return s.overrides, nil
+}
+
+func (s *stubGuardianOverrideStore) Save(_ context.Context, override *model.GuardianOverride) error {
+	override.Id = int64(len(s.overrides) + 1)
+	s.overrides = append(s.overrides, override)
+	return nil
+}
+
+func (s *stubGuardianOverrideStore) Delete(context.Context, int64, string) (*model.GuardianOverride, error) {
+	return nil, nil
+}
+
+func (s *stubGuardianOverrideStore) ListAudit(context.Context, int) ([]*model.GuardianOverrideAudit, error) {
+	return nil, nil
+}
+
+func TestGuardianOverridesVerdicts(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	overrideStore := &stubGuardianOverrideStore{overrides: []*model.GuardianOverride{
+		{Kind: model.OverrideDeny, Granularity: model.OverrideDomain, Pattern: "evil.example"},
+		{Kind: model.OverrideAllow, Granularity: model.OverrideHost, Pattern: "docs.evil.example"},
+		{Kind: model.OverrideAllow, Granularity: model.OverridePathPrefix, Pattern: "files.example.com/public/"},
+		{Kind: model.OverrideDeny, Granularity: model.OverrideExact, Pattern: "example.org/old", ExpiresAt: &expired},
+		{Kind: model.OverrideDeny, Granularity: model.OverrideExact, Pattern: "example.com/report"},
+	}}
+	overrides := NewGuardianOverrides(overrideStore, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	urls := []string{
+		"https://login.evil.example/",
+		"https://docs.evil.example/guide",
+		"http://files.example.com/public/report.pdf",
+		"https://example.org/old",
+		"https://example.net/",
+		"https://example.com/report?utm=1",
+	}
+	want := []OverrideVerdict{VerdictDeny, VerdictDeny, VerdictAllow, VerdictNone, VerdictNone, VerdictDeny}
+	verdicts, err := overrides.Verdicts(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, verdict := range verdicts {
+		if verdict != want[i] {
+			t.Errorf("%s got verdict %d, want %d", urls[i], verdict, want[i])
+		}
+	}
+
+	t.Run("create reloads the overrides", func(t *testing.T) {
+		createReq := model.CreateGuardianOverrideReq{Kind: model.OverrideAllow, URL: "https://example.net/", Granularity: model.OverrideHost, Reason: "Own site"}
+		override, err := overrides.Create(context.Background(), "security", createReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if override.Pattern != "example.net" {
+			t.Errorf("got pattern %q, want %q", override.Pattern, "example.net")
+		}
+
+		verdicts, err := overrides.Verdicts(context.Background(), []string{"https://example.net/about"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdicts[0] != VerdictAllow {
+			t.Errorf("got verdict %d, want %d", verdicts[0], VerdictAllow)
+		}
+		if overrideStore.loads != 2 {
+			t.Errorf("got %d loads, want %d", overrideStore.loads, 2)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// obfuscatedIdBits is the width of the permuted ID space. 48 bits keep the base62 encoded slugs at most 9
+// characters long while leaving room for 2^48 IDs.
+const obfuscatedIdBits = 48
+const obfuscatedIdHalfBits = obfuscatedIdBits / 2
+const obfuscatedIdHalfMask = 1<<obfuscatedIdHalfBits - 1
+const feistelRounds = 4
+
+var ErrIdOutOfRange = errors.New("the ID is out of the obfuscated ID space")
+
+// IDObfuscator is a reversible permutation of the IDs, which makes slugs generated from consecutive IDs unguessable.
+type IDObfuscator interface {
+	Obfuscate(id int64) (int64, error)
+	Deobfuscate(obfuscatedId int64) (int64, error)
+}
+
+// feistelIDObfuscator permutes 48-bit IDs with balanced Feistel network whose round function is keyed HMAC-SHA256.
+type feistelIDObfuscator struct {
+	key []byte
+}
+
+func (f *feistelIDObfuscator) Obfuscate(id int64) (int64, error) {
+	if id < 0 || id>>obfuscatedIdBits != 0 {
+		return 0, ErrIdOutOfRange
+	}
+
+	left, right := uint64(id)>>obfuscatedIdHalfBits, uint64(id)&obfuscatedIdHalfMask
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^f.round(round, right)
+	}
+
+	return int64(left<<obfuscatedIdHalfBits | right), nil
+}
+
+func (f *feistelIDObfuscator) Deobfuscate(obfuscatedId int64) (int64, error) {
+	if obfuscatedId < 0 || obfuscatedId>>obfuscatedIdBits != 0 {
+		return 0, ErrIdOutOfRange
+	}
+
+	left, right := uint64(obfuscatedId)>>obfuscatedIdHalfBits, uint64(obfuscatedId)&obfuscatedIdHalfMask
+	for round := feistelRounds - 1; round >= 0; round-- {
+		left, right = right^f.round(round, left), left
+	}
+
+	return int64(left<<obfuscatedIdHalfBits | right), nil
+}
+
+func (f *feistelIDObfuscator) round(round int, half uint64) uint64 {
+	var input [9]byte
+	input[0] = byte(round)
+	binary.BigEndian.PutUint64(input[1:], half)
+
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write(input[:])
+
+	return binary.BigEndian.Uint64(mac.Sum(nil)) & obfuscatedIdHalfMask
+}
+
+// identityIDObfuscator keeps the IDs as they are, it's used when no obfuscation secret is configured.
+type identityIDObfuscator struct{}
+
+func (identityIDObfuscator) Obfuscate(id int64) (int64, error) {
+	return id, nil
+}
+
+func (identityIDObfuscator) Deobfuscate(obfuscatedId int64) (int64, error) {
+	return obfuscatedId, nil
+}
+
+// NewIDObfuscator creates keyed obfuscator or the identity one when the key is empty.
+func NewIDObfuscator(key []byte) IDObfuscator {
+	if len(key) == 0 {
+		return identityIDObfuscator{}
+	}
+
+	return &feistelIDObfuscator{key: key}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestFeistelIDObfuscator(t *testing.T) {
+	obfuscator := NewIDObfuscator([]byte("7f3c2a9e5b1d4c8f"))
+
+	seen := make(map[int64]int64)
+	for id := int64(1); id <= 10_000; id++ {
+		obfuscatedId, err := obfuscator.Obfuscate(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previousId, ok := seen[obfuscatedId]; ok {
+			t.Fatalf("IDs %d and %d are both obfuscated to %d", previousId, id, obfuscatedId)
+		}
+		seen[obfuscatedId] = id
+
+		deobfuscatedId, err := obfuscator.Deobfuscate(obfuscatedId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deobfuscatedId != id {
+			t.Errorf("Deobfuscate(Obfuscate(%d)) = %d", id, deobfuscatedId)
+		}
+	}
+
+	if _, err := obfuscator.Obfuscate(1 << obfuscatedIdBits); err == nil {
+		t.Errorf("got no error for ID out of range")
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"log/slog"
+	"time"
+)
+
+// LeaderElection makes single replica at a time the leader running the work, which shouldn't be done concurrently,
+// and fails over to another replica when the leader dies.
+type LeaderElection interface {
+	// Run campaigns for the leadership until the context is done. Whenever the replica becomes the leader, lead is
+	// called with the fencing token of the leadership and context, which is cancelled as soon as the leadership is
+	// lost. Run doesn't campaign again until lead returns.
+	Run(ctx context.Context, lead func(ctx context.Context, token int64))
+}
+
+type leaderElection struct {
+	name          string
+	lease         store.Lease
+	renewInterval time.Duration
+	logger        *slog.Logger
+}
+
+func (e *leaderElection) Run(ctx context.Context, lead func(ctx context.Context, token int64)) {
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+	for {
+		token, err := e.lease.Acquire(ctx)
+		switch {
+		case err == nil:
+			e.logger.InfoContext(ctx, "Became the leader.", "election", e.name, "token", token)
+			e.lead(ctx, ticker, token, lead)
+		case !errors.Is(err, store.ErrLeaseHeld) && ctx.Err() == nil:
+			e.logger.ErrorContext(ctx, "Error while acquiring the leadership.", "election", e.name, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead runs the work and keeps renewing the lease until either the work returns or the leadership is lost.
+func (e *leaderElection) lead(ctx context.Context, ticker *time.Ticker, token int64, lead func(ctx context.Context, token int64)) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx, token)
+	}()
+
+	defer func() {
+		cancel()
+		<-done
+		// The lease is released with fresh context, as the given one may be done already.
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), e.renewInterval)
+		defer releaseCancel()
+		if err := e.lease.Release(releaseCtx, token); err != nil {
+			e.logger.Error("Error while releasing the leadership.", "election", e.name, "err", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// The leadership is given up on any renewal failure, as the lease may expire before the next attempt.
+			if err := e.lease.Renew(ctx, token); err != nil {
+				if ctx.Err() == nil {
+					e.logger.WarnContext(ctx, "Lost the leadership.", "election", e.name, "token", token, "err", err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// NewLeaderElection creates election of the lease holders. The lease is renewed every renewInterval, which should be
+// a fraction of the lease's TTL.
+func NewLeaderElection(name string, lease store.Lease, renewInterval time.Duration, logger *slog.Logger) LeaderElection {
+	return &leaderElection{
+		name:          name,
+		lease:         lease,
+		renewInterval: renewInterval,
+		logger:        logger,
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stubLease struct {
+	mu       sync.Mutex
+	token    int64
+	held     bool
+	renewals int
+	// maxRenewals is the number of renewals after which the lease is lost.
+	maxRenewals int
+	released    []int64
+}
+
+func (l *stubLease) Acquire(context.Context) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held {
+		return 0, store.ErrLeaseHeld
+	}
+	l.held, l.renewals = true, 0
+	l.token++
+
+	return l.token, nil
+}
+
+func (l *stubLease) Renew(_ context.Context, token int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.renewals++
+	if !l.held || token != l.token || l.renewals > l.maxRenewals {
+		l.held = false
+		return store.ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (l *stubLease) Release(_ context.Context, token int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = append(l.released, token)
+	if token == l.token {
+		l.held = false
+	}
+
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	lease := &stubLease{maxRenewals: 2}
+	election := NewLeaderElection("test", lease, time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var tokens []int64
+	election.Run(ctx, func(leadCtx context.Context, token int64) {
+		tokens = append(tokens, token)
+		// The leadership is lost after two renewals, which has to cancel the context of the leader.
+		<-leadCtx.Done()
+		if len(tokens) == 2 {
+			cancel()
+		}
+	})
+
+	if len(tokens) != 2 || tokens[0] != 1 || tokens[1] != 2 {
+		t.Errorf("got leaderships with tokens %v, want [1 2]", tokens)
+	}
+	if len(lease.released) != 2 {
+		t.Errorf("got %d releases, want %d", len(lease.released), 2)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrGuardianStale = errors.New("the threat feed has not been refreshed recently")
+
+// ReadinessCheck checks single dependency of snip.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Degradable checks report the replica as degraded rather than unready when they fail, as the replica is still
+	// able to serve the traffic.
+	Degradable bool
+}
+
+// Readiness decides whether the replica can serve traffic.
+type Readiness interface {
+	// Check runs all the checks concurrently and reports the health of each dependency.
+	Check(ctx context.Context) model.ReadinessRes
+	// Drain makes the replica unready from now on, so that the traffic is moved away before the shutdown.
+	Drain()
+}
+
+type readiness struct {
+	checks   []ReadinessCheck
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func (r *readiness) Check(ctx context.Context) model.ReadinessRes {
+	res := model.ReadinessRes{
+		Ready:        !r.draining.Load(),
+		Draining:     r.draining.Load(),
+		Dependencies: make([]model.DependencyHealth, len(r.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			health := model.DependencyHealth{Name: check.Name, Healthy: err == nil, Degradable: check.Degradable, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				health.Error = err.Error()
+			}
+			res.Dependencies[i] = health
+		}()
+	}
+	wg.Wait()
+
+	for _, dependency := range res.Dependencies {
+		if dependency.Degradable {
+			res.Degraded = res.Degraded || !dependency.Healthy
+			continue
+		}
+		res.Ready = res.Ready && dependency.Healthy
+	}
+
+	return res
+}
+
+func (r *readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// GuardianFreshnessCheck fails when any of the guardian's providers hasn't been refreshed within maxAge, as the
+// guardian would let through URLs reported since then. The stale feeds degrade the replica instead of making it
+// unready, as the feeds are shared by all the replicas and draining them all would take the whole service down.
+func GuardianFreshnessCheck(guardian URLGuardian, maxAge time.Duration) ReadinessCheck {
+	return ReadinessCheck{
+		Name:       "guardian",
+		Degradable: true,
+		Check: func(ctx context.Context) error {
+			var errs []error
+			for _, health := range guardian.Health(ctx) {
+				switch {
+				case health.LastUpdatedAt == nil:
+					errs = append(errs, fmt.Errorf("%w: %s has never been refreshed", ErrGuardianStale, health.Name))
+				case time.Since(*health.LastUpdatedAt) > maxAge:
+					age := time.Since(*health.LastUpdatedAt).Round(time.Second)
+					errs = append(errs, fmt.Errorf("%w: %s has been refreshed %v ago", ErrGuardianStale, health.Name, age))
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+// NewReadiness creates readiness running the given checks, each of which has to finish within the timeout.
+func NewReadiness(checks []ReadinessCheck, timeout time.Duration) Readiness {
+	return &readiness{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
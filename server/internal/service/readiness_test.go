@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
+	"testing"
+	"time"
+)
+
+type stubURLGuardian struct {
+	lastUpdatedAt *time.Time
+}
+
+func (g *stubURLGuardian) SafeURL(context.Context, string) (bool, error) {
+	return true, nil
+}
+
+func (g *stubURLGuardian) SafeURLs(_ context.Context, urls []string) ([]bool, error) {
+	return make([]bool, len(urls)), nil
+}
+
+func (g *stubURLGuardian) UpdateDB(context.Context, int64) (int, error) {
+	return 0, nil
+}
+
+func (g *stubURLGuardian) Health(context.Context) []model.ThreatProviderHealth {
+	return []model.ThreatProviderHealth{{Name: "urlhaus", LastUpdatedAt: g.lastUpdatedAt}}
+}
+
+func (g *stubURLGuardian) Snapshot(context.Context) ([]threatfeed.Entry, error) {
+	return nil, nil
+}
+
+func TestReadiness(t *testing.T) {
+	healthy := ReadinessCheck{Name: "postgres", Check: func(context.Context) error { return nil }}
+	hanging := ReadinessCheck{Name: "valkey", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	t.Run("healthy dependencies", func(t *testing.T) {
+		res := NewReadiness([]ReadinessCheck{healthy}, time.Second).Check(context.Background())
+		if !res.Ready || len(res.Dependencies) != 1 || !res.Dependencies[0].Healthy {
+			t.Errorf("got %+v, want ready", res)
+		}
+	})
+
+	t.Run("hanging dependency times out", func(t *testing.T) {
+		res := NewReadiness([]ReadinessCheck{healthy, hanging}, 10*time.Millisecond).Check(context.Background())
+		if res.Ready {
+			t.Errorf("got ready, want unready")
+		}
+		if valkey := res.Dependencies[1]; valkey.Healthy || valkey.Error != context.DeadlineExceeded.Error() {
+			t.Errorf("got %+v, want timed out valkey", valkey)
+		}
+	})
+
+	t.Run("unhealthy degradable dependency", func(t *testing.T) {
+		stale := ReadinessCheck{Name: "guardian", Degradable: true, Check: func(context.Context) error { return ErrGuardianStale }}
+		res := NewReadiness([]ReadinessCheck{healthy, stale}, time.Second).Check(context.Background())
+		if !res.Ready || !res.Degraded {
+			t.Errorf("got %+v, want ready and degraded", res)
+		}
+		if guardian := res.Dependencies[1]; guardian.Healthy || !guardian.Degradable {
+			t.Errorf("got %+v, want unhealthy degradable guardian", guardian)
+		}
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		readiness := NewReadiness([]ReadinessCheck{healthy}, time.Second)
+		readiness.Drain()
+		if res := readiness.Check(context.Background()); res.Ready || !res.Draining {
+			t.Errorf("got %+v, want draining", res)
+		}
+	})
+}
+
+func TestGuardianFreshnessCheck(t *testing.T) {
+	fresh, stale := time.Now().Add(-time.Minute), time.Now().Add(-time.Hour)
+	tests := map[string]struct {
+		lastUpdatedAt *time.Time
+		wantErr       error
+	}{
+		"fresh feed":        {lastUpdatedAt: &fresh},
+		"stale feed":        {lastUpdatedAt: &stale, wantErr: ErrGuardianStale},
+		"never loaded feed": {wantErr: ErrGuardianStale},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			guardian := &stubURLGuardian{lastUpdatedAt: tt.lastUpdatedAt}
+			err := GuardianFreshnessCheck(guardian, 15*time.Minute).Check(context.Background())
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
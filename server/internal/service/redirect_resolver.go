@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrTooManyRedirects = errors.New("the URL redirects too many times")
+var ErrRedirectLoop = errors.New("the URL redirects in a loop")
+var ErrPrivateDestination = errors.New("the URL leads to a private network")
+
+// RedirectResolver follows the HTTP redirects of URLs so that the guardian can check their final destinations.
+type RedirectResolver interface {
+	// Resolve returns the chain of the URLs visited starting with the given URL and ending with the destination.
+	// Chains which are too long, loop or lead to a private network are refused with the corresponding error.
+	Resolve(ctx context.Context, rawURL string) ([]string, error)
+}
+
+type redirectResolver struct {
+	client  *http.Client
+	ownHost string
+	maxHops int
+}
+
+func (r *redirectResolver) Resolve(ctx context.Context, rawURL string) ([]string, error) {
+	chain := []string{rawURL}
+	visited := map[string]struct{}{rawURL: {}}
+	current := rawURL
+	for {
+		u, err := url.Parse(current)
+		if err != nil {
+			return chain, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			// Redirects to other schemes can't be followed any further.
+			return chain, nil
+		}
+		if r.ownHost != "" && strings.EqualFold(u.Hostname(), r.ownHost) {
+			return chain, ErrRedirectLoop
+		}
+
+		next, err := r.next(ctx, u)
+		if err != nil {
+			return chain, err
+		}
+		if next == "" {
+			return chain, nil
+		}
+
+		if len(chain) > r.maxHops {
+			return chain, ErrTooManyRedirects
+		}
+		if _, ok := visited[next]; ok {
+			return chain, ErrRedirectLoop
+		}
+		visited[next] = struct{}{}
+		chain = append(chain, next)
+		current = next
+	}
+}
+
+// next requests the URL and returns the location it redirects to or empty string when it doesn't redirect.
+func (r *redirectResolver) next(ctx context.Context, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrPrivateDestination) {
+			return "", ErrPrivateDestination
+		}
+		return "", err
+	}
+	// Only the headers are of interest, the body is never read.
+	_ = res.Body.Close()
+
+	if res.StatusCode < 300 || res.StatusCode >= 400 {
+		return "", nil
+	}
+	location, err := res.Location()
+	if err != nil {
+		if errors.Is(err, http.ErrNoLocation) {
+			return "", nil
+		}
+		return "", err
+	}
+	location.Fragment = ""
+
+	return location.String(), nil
+}
+
+// denyPrivateAddresses refuses connections to addresses which aren't publicly routable. It's checked on the actually
+// dialed address, so that DNS records resolving to private addresses can't get around it.
+func denyPrivateAddresses(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if violation := addressViolation(addr); violation != nil {
+		return fmt.Errorf("%w: %w", ErrPrivateDestination, violation)
+	}
+
+	return nil
+}
+
+// ownHostname extracts the hostname of SNIP_HOSTNAME, which may be given with or without scheme.
+func ownHostname(hostname string) string {
+	if !strings.Contains(hostname, "://") {
+		hostname = "//" + hostname
+	}
+	u, err := url.Parse(hostname)
+	if err != nil {
+		return hostname
+	}
+
+	return u.Hostname()
+}
+
+func newRedirectResolver(client *http.Client, hostname string, maxHops int) *redirectResolver {
+	// The redirects are followed by the resolver itself to check each of them.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &redirectResolver{
+		client:  client,
+		ownHost: ownHostname(hostname),
+		maxHops: maxHops,
+	}
+}
+
+// NewRedirectResolver creates resolver following up to maxHops redirects, each of which has to respond within the
+// timeout. Redirects back to the given hostname of snip are refused as loops.
+func NewRedirectResolver(hostname string, maxHops int, timeout time.Duration) RedirectResolver {
+	dialer := &net.Dialer{Timeout: timeout, Control: denyPrivateAddresses}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// The proxy is bypassed, otherwise only its address would be checked.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       time.Minute,
+		},
+	}
+
+	return newRedirectResolver(client, hostname, maxHops)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedirectResolver(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/a", http.RedirectHandler("/b", http.StatusMovedPermanently))
+	mux.Handle("/b", http.RedirectHandler("/c#fragment", http.StatusFound))
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle("/x", http.RedirectHandler("/y", http.StatusFound))
+	mux.Handle("/y", http.RedirectHandler("/x", http.StatusFound))
+	mux.HandleFunc("/hop/{n}", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.PathValue("n"))
+		http.Redirect(w, r, "/hop/"+strconv.Itoa(n+1), http.StatusFound)
+	})
+	mux.Handle("/snip", http.RedirectHandler("https://snip.example/abcd", http.StatusFound))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resolver := newRedirectResolver(srv.Client(), "https://snip.example", 3)
+	ctx := context.Background()
+
+	t.Run("follow redirects", func(t *testing.T) {
+		chain, err := resolver.Resolve(ctx, srv.URL+"/a")
+		if err != nil {
+			t.Fatalf("Resolve returned error: %v", err)
+		}
+		want := []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+		if !slices.Equal(chain, want) {
+			t.Errorf("got %q, want %q", chain, want)
+		}
+	})
+
+	t.Run("refuse too many redirects", func(t *testing.T) {
+		chain, err := resolver.Resolve(ctx, srv.URL+"/hop/0")
+		if !errors.Is(err, ErrTooManyRedirects) {
+			t.Errorf("got %v, want %v", err, ErrTooManyRedirects)
+		}
+		if len(chain) != 4 {
+			t.Errorf("got %d hops, want %d", len(chain), 4)
+		}
+	})
+
+	t.Run("refuse redirect loop", func(t *testing.T) {
+		if _, err := resolver.Resolve(ctx, srv.URL+"/x"); !errors.Is(err, ErrRedirectLoop) {
+			t.Errorf("got %v, want %v", err, ErrRedirectLoop)
+		}
+	})
+
+	t.Run("refuse redirect to own hostname", func(t *testing.T) {
+		chain, err := resolver.Resolve(ctx, srv.URL+"/snip")
+		if !errors.Is(err, ErrRedirectLoop) {
+			t.Errorf("got %v, want %v", err, ErrRedirectLoop)
+		}
+		if chain[len(chain)-1] != "https://snip.example/abcd" {
+			t.Errorf("got %q as last hop, want the snip URL", chain[len(chain)-1])
+		}
+	})
+
+	t.Run("refuse private destination", func(t *testing.T) {
+		// The test server listens on the loopback interface, which is refused by the default resolver.
+		_, err := NewRedirectResolver("snip.example", 3, time.Second).Resolve(ctx, srv.URL+"/c")
+		if !errors.Is(err, ErrPrivateDestination) {
+			t.Errorf("got %v, want %v", err, ErrPrivateDestination)
+		}
+	})
+}
+
+func TestOwnHostname(t *testing.T) {
+	for hostname, want := range map[string]string{
+		"https://snip.local":      "snip.local",
+		"snip.local:8443":         "snip.local",
+		"http://SNIP.local:8081/": "SNIP.local",
+	} {
+		if got := ownHostname(hostname); !strings.EqualFold(got, want) {
+			t.Errorf("ownHostname(%q) = %q, want %q", hostname, got, want)
+		}
+	}
+}
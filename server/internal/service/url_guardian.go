@@ -2,93 +2,594 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/aboyadzhiev/snip/server/internal/urlhaus"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
 	"github.com/valkey-io/valkey-go"
 	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 )
 
 const maliciousURLsKey = "MaliciousURLs"
-const maliciousURLsRefreshedKey = "MaliciousURLsRefreshed"
+const maliciousPathPrefixesKey = "MaliciousPathPrefixes"
+const maliciousHostsKey = "MaliciousHosts"
+const maliciousDomainsKey = "MaliciousDomains"
+
+// stagingTTL bounds the lifetime of the staging sets left behind by failed refreshes.
+const stagingTTL = time.Hour
 const maliciousURLsLastUpdatedAtKey = "MaliciousURLsLastUpdatedAt"
+const maliciousURLsFencingTokenKey = "MaliciousURLsFencingToken"
+const maliciousURLsVersionKey = "MaliciousURLsVersion"
+
+// legacyKeys are the keys of the single set which the guardian kept before the providers got sets of their own.
+var legacyKeys = []string{maliciousURLsKey, maliciousURLsKey + "Refreshed", maliciousURLsLastUpdatedAtKey}
+
+var ErrStaleFencingToken = errors.New("the sets have been refreshed by a newer leader")
+var ErrEmptyFeed = errors.New("the feed has no entries")
+
+// saddBatchSize limits the number of members added to the staging sets by single SADD.
+const saddBatchSize = 1000
+
+// maliciousSetKeys are the keys of the sets holding the reported URLs at each granularity.
+var maliciousSetKeys = map[granularity]string{
+	granularityExact:      maliciousURLsKey,
+	granularityPathPrefix: maliciousPathPrefixesKey,
+	granularityHost:       maliciousHostsKey,
+	granularityDomain:     maliciousDomainsKey,
+}
 
 type URLGuardian interface {
+	// SafeURL determines whether the URL is safe. When the guardian has redirect resolver, every URL the given one
+	// redirects to has to be safe as well.
 	SafeURL(ctx context.Context, url string) (bool, error)
-	UpdateDB(ctx context.Context) error
+	// SafeURLs determines whether the given URLs are safe with single Valkey call. The redirects aren't followed, as
+	// resolving large batches would take too long.
+	SafeURLs(ctx context.Context, urls []string) ([]bool, error)
+	// UpdateDB refreshes the malicious URLs of the providers whose refresh interval has elapsed and returns how many
+	// of them have been refreshed. The refreshes are refused with ErrStaleFencingToken once the sets have been
+	// written with greater fencing token of the leader election.
+	UpdateDB(ctx context.Context, fencingToken int64) (int, error)
+	Health(ctx context.Context) []model.ThreatProviderHealth
+	// Snapshot returns the entries of all the providers in the form which the file feed can load back, so that
+	// the guardian can be seeded without access to the online feeds.
+	Snapshot(ctx context.Context) ([]threatfeed.Entry, error)
+}
+
+// ThreatProvider is a threat feed refreshed on its own schedule into its own Valkey set.
+type ThreatProvider struct {
+	Feed            threatfeed.Feed
+	RefreshInterval time.Duration
+}
+
+type threatProviderState struct {
+	lastError   error
+	lastErrorAt time.Time
+	// added and removed count the members of the sets changed by the last refresh, the totals by all of them.
+	added        int64
+	removed      int64
+	totalAdded   int64
+	totalRemoved int64
 }
 
+// urlGuardian considers URL unsafe when any of its providers reports it.
 type urlGuardian struct {
-	urlhausClient urlhaus.Client
-	valkeyClient  valkey.Client
-	logger        *slog.Logger
+	providers    []ThreatProvider
+	valkeyClient valkey.Client
+	resolver     RedirectResolver
+	overrides    GuardianOverrides
+	logger       *slog.Logger
+
+	mu                sync.Mutex
+	states            map[string]*threatProviderState
+	legacyKeysDeleted bool
 }
 
 func (u *urlGuardian) SafeURL(ctx context.Context, url string) (bool, error) {
-	isMember, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Sismember().Key(maliciousURLsKey).Member(url).Build()).AsBool()
+	chain := []string{url}
+	if u.resolver != nil {
+		resolved, err := u.resolver.Resolve(ctx, url)
+		if errors.Is(err, ErrTooManyRedirects) || errors.Is(err, ErrRedirectLoop) || errors.Is(err, ErrPrivateDestination) {
+			u.logger.WarnContext(ctx, "Refused URL redirecting to forbidden destination.", "url", url, "chain", resolved, "err", err)
+			return false, nil
+		}
+		if err != nil {
+			// Unreachable destinations can't be shortened into anything harmful, so the hops visited so far are checked.
+			u.logger.DebugContext(ctx, "Error while resolving redirects of URL.", "url", url, "err", err)
+		}
+		if len(resolved) > 0 {
+			chain = resolved
+		}
+	}
+
+	safe, err := u.SafeURLs(ctx, chain)
 	if err != nil {
 		u.logger.Error("Error while determining whether URL is safe.", "url", url, "err", err)
 		return false, err
 	}
 
-	return !isMember, nil
+	for _, safeHop := range safe {
+		if !safeHop {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (u *urlGuardian) SafeURLs(ctx context.Context, urls []string) ([]bool, error) {
+	safe := make([]bool, len(urls))
+	for i := range safe {
+		safe[i] = true
+	}
+	if len(urls) == 0 {
+		return safe, nil
+	}
+
+	// The denied URLs aren't looked up in the feeds at all, the allowed ones are looked up but their verdict wins.
+	verdicts := make([]OverrideVerdict, len(urls))
+	if u.overrides != nil {
+		var err error
+		if verdicts, err = u.overrides.Verdicts(ctx, urls); err != nil {
+			u.logger.Error("Error while applying the guardian overrides.", "urls", len(urls), "err", err)
+			return nil, err
+		}
+	}
+	lookup := false
+	for i, verdict := range verdicts {
+		if verdict == VerdictDeny {
+			safe[i] = false
+			continue
+		}
+		lookup = true
+	}
+	if !lookup || len(u.providers) == 0 {
+		return safe, nil
+	}
+
+	// The URLs are looked up at every granularity, the members of each set are mapped back to the URLs by indexes.
+	members := make(map[granularity][]string, len(maliciousSetKeys))
+	indexes := make(map[granularity][]int, len(maliciousSetKeys))
+	addMember := func(g granularity, index int, member string) {
+		members[g] = append(members[g], member)
+		indexes[g] = append(indexes[g], index)
+	}
+	for i, url := range urls {
+		if verdicts[i] == VerdictDeny {
+			continue
+		}
+		keys, err := matchKeys(url)
+		if err != nil {
+			// Unparsable URLs can still be reported verbatim.
+			addMember(granularityExact, i, url)
+			continue
+		}
+		for _, exact := range keys.exactKeys() {
+			addMember(granularityExact, i, exact)
+		}
+		for _, pathPrefix := range keys.pathPrefixes {
+			addMember(granularityPathPrefix, i, pathPrefix)
+		}
+		addMember(granularityHost, i, keys.host)
+		if keys.domain != "" {
+			addMember(granularityDomain, i, keys.domain)
+		}
+	}
+
+	// The sets may belong to different cluster slots, so they are queried with separate pipelined commands.
+	var cmds valkey.Commands
+	var granularities []granularity
+	for _, provider := range u.providers {
+		for g, setKey := range maliciousSetKeys {
+			if len(members[g]) == 0 {
+				continue
+			}
+			cmds = append(cmds, u.valkeyClient.B().Smismember().Key(providerKey(setKey, provider)).Member(members[g]...).Build())
+			granularities = append(granularities, g)
+		}
+	}
+	for i, res := range u.valkeyClient.DoMulti(ctx, cmds...) {
+		areMembers, err := res.AsBoolSlice()
+		if err != nil {
+			u.logger.Error("Error while determining whether URLs are safe.", "urls", len(urls), "err", err)
+			return nil, err
+		}
+		for j, isMember := range areMembers {
+			if index := indexes[granularities[i]][j]; isMember && verdicts[index] != VerdictAllow {
+				safe[index] = false
+			}
+		}
+	}
+
+	return safe, nil
+}
+
+func (u *urlGuardian) UpdateDB(ctx context.Context, fencingToken int64) (int, error) {
+	u.deleteLegacyKeys(ctx)
+
+	var refreshed int
+	var errs []error
+	for _, provider := range u.providers {
+		updated, err := u.updateProvider(ctx, provider, fencingToken)
+		if err != nil {
+			u.recordError(provider, err)
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Feed.Name(), err))
+			continue
+		}
+		if updated {
+			refreshed++
+		}
+	}
+
+	return refreshed, errors.Join(errs...)
 }
 
-func (u *urlGuardian) UpdateDB(ctx context.Context) error {
-	// Checking the time from the last call to comply with their API requirements. See https://urlhaus.abuse.ch/api/
-	exists, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Exists().Key(maliciousURLsLastUpdatedAtKey).Build()).AsBool()
+func (u *urlGuardian) updateProvider(ctx context.Context, provider ThreatProvider, fencingToken int64) (bool, error) {
+	name := provider.Feed.Name()
+
+	// Checking the time from the last call to comply with the feeds' requirements e.g. see https://urlhaus.abuse.ch/api/
+	lastUpdatedAt, err := u.lastUpdatedAt(ctx, provider)
+	if err != nil {
+		return false, err
+	}
+	if lastUpdatedAt != nil {
+		elapsed := time.Since(*lastUpdatedAt).Round(time.Second)
+		if elapsed < provider.RefreshInterval {
+			u.logger.DebugContext(ctx, fmt.Sprintf("The %s feed has been updated %v ago (< %v) - skipping..", name, elapsed, provider.RefreshInterval))
+			return false, nil
+		}
+	}
+
+	entries, version, err := u.fetch(ctx, provider)
+	if errors.Is(err, threatfeed.ErrNotModified) {
+		u.logger.InfoContext(ctx, fmt.Sprintf("The %s feed has not been modified.", name))
+		err = u.valkeyClient.Do(ctx, u.valkeyClient.B().Set().Key(providerKey(maliciousURLsLastUpdatedAtKey, provider)).Value(time.Now().UTC().Format(time.RFC3339)).Build()).Error()
+		if err != nil {
+			return false, err
+		}
+		u.recordRefresh(provider, 0, 0)
+		return false, nil
+	}
 	if err != nil {
-		return err
+		return false, err
 	}
-	if exists {
-		lastUpdatedAt, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Get().Key(maliciousURLsLastUpdatedAtKey).Build()).ToString()
+	if len(entries) == 0 {
+		// Truncated downloads would otherwise wipe out the live sets.
+		return false, ErrEmptyFeed
+	}
+
+	members := make(map[granularity][]string, len(maliciousSetKeys))
+	for _, entry := range entries {
+		keys, err := matchKeys(entry.URL)
 		if err != nil {
+			members[granularityExact] = append(members[granularityExact], entry.URL)
+			continue
+		}
+		members[granularityExact] = append(members[granularityExact], keys.exact)
+		switch g := entryGranularity(entry, keys); {
+		case g == granularityDomain && keys.domain != "":
+			members[granularityDomain] = append(members[granularityDomain], keys.domain)
+		case g >= granularityHost:
+			members[granularityHost] = append(members[granularityHost], keys.host)
+		case g == granularityPathPrefix:
+			members[granularityPathPrefix] = append(members[granularityPathPrefix], keys.pathPrefixes[len(keys.pathPrefixes)-1])
+		}
+	}
+
+	added, removed, err := u.swapSets(ctx, provider, members, version, fencingToken)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "Error while refreshing malicious sets.", "feed", name, "err", err)
+		return false, err
+	}
+	u.logger.InfoContext(ctx, fmt.Sprintf("Refreshed the %s feed with %d entries, added %d and removed %d members.", name, len(entries), added, removed))
+	u.recordRefresh(provider, added, removed)
+
+	return true, nil
+}
+
+// deleteLegacyKeys deletes the legacy keys once the instance becomes the leader, as nothing reads them anymore.
+// The deletion is retried by the next refresh when it fails.
+func (u *urlGuardian) deleteLegacyKeys(ctx context.Context) {
+	u.mu.Lock()
+	deleted := u.legacyKeysDeleted
+	u.mu.Unlock()
+	if deleted {
+		return
+	}
+
+	// The keys may belong to different cluster slots, so they are deleted with separate commands.
+	cmds := make(valkey.Commands, 0, len(legacyKeys))
+	for _, key := range legacyKeys {
+		cmds = append(cmds, u.valkeyClient.B().Del().Key(key).Build())
+	}
+	for _, res := range u.valkeyClient.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			u.logger.WarnContext(ctx, "Error while deleting legacy keys.", "err", err)
+			return
+		}
+	}
+
+	u.mu.Lock()
+	u.legacyKeysDeleted = true
+	u.mu.Unlock()
+}
+
+// fetch fetches the entries of the provider along with their version. Conditional feeds are fetched only when they
+// have changed since the version of the live sets, which is kept along with them so that it survives failovers.
+func (u *urlGuardian) fetch(ctx context.Context, provider ThreatProvider) ([]threatfeed.Entry, string, error) {
+	conditional, ok := provider.Feed.(threatfeed.ConditionalFeed)
+	if !ok {
+		entries, err := provider.Feed.Fetch(ctx)
+		return entries, "", err
+	}
+
+	version, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Get().Key(providerKey(maliciousURLsVersionKey, provider)).Build()).ToString()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		return nil, "", err
+	}
+
+	return conditional.FetchIfModified(ctx, version)
+}
+
+// swapSets builds new version of the provider's sets in staging keys and then replaces the live sets with them in
+// single transaction, so that the readers never see partially refreshed sets. It returns the number of members added
+// and removed by the swap.
+func (u *urlGuardian) swapSets(ctx context.Context, provider ThreatProvider, members map[granularity][]string, version string, fencingToken int64) (added int64, removed int64, err error) {
+	var stagingKeys []string
+	defer func() {
+		// The staging sets of failed swap are dropped right away rather than left to expire.
+		if err != nil && len(stagingKeys) > 0 {
+			u.valkeyClient.Do(ctx, u.valkeyClient.B().Del().Key(stagingKeys...).Build())
+		}
+	}()
+	// The keys of the provider share hash tag, so the transaction is valid in cluster as well.
+	swap := valkey.Commands{u.valkeyClient.B().Multi().Build()}
+	for g, setKey := range maliciousSetKeys {
+		liveKey := providerKey(setKey, provider)
+		// The staging sets are versioned by the fencing token, so that concurrent leaders never mix their sets.
+		stagingKey := fmt.Sprintf("%s:staging:%d", liveKey, fencingToken)
+		stagingKeys = append(stagingKeys, stagingKey)
+
+		// Leftovers of failed refresh are dropped first.
+		cmds := valkey.Commands{u.valkeyClient.B().Del().Key(stagingKey).Build()}
+		for batch := range slices.Chunk(members[g], saddBatchSize) {
+			cmds = append(cmds, u.valkeyClient.B().Sadd().Key(stagingKey).Member(batch...).Build())
+		}
+		cmds = append(cmds,
+			u.valkeyClient.B().Expire().Key(stagingKey).Seconds(int64(stagingTTL.Seconds())).Build(),
+			u.valkeyClient.B().Scard().Key(stagingKey).Build(),
+			u.valkeyClient.B().Scard().Key(liveKey).Build(),
+			u.valkeyClient.B().Sintercard().Numkeys(2).Key(stagingKey, liveKey).Build(),
+		)
+		results := u.valkeyClient.DoMulti(ctx, cmds...)
+		for _, res := range results {
+			if err := res.Error(); err != nil {
+				return 0, 0, err
+			}
+		}
+		counts := make([]int64, 3)
+		for i, res := range results[len(results)-3:] {
+			counts[i], _ = res.AsInt64()
+		}
+		stagingCount, liveCount, common := counts[0], counts[1], counts[2]
+		added += stagingCount - common
+		removed += liveCount - common
+
+		if stagingCount == 0 {
+			// Empty sets don't exist, so there is nothing to rename.
+			swap = append(swap, u.valkeyClient.B().Del().Key(liveKey).Build())
+			continue
+		}
+		// The live set keeps the TTL of the staging set after the rename, so it's removed.
+		swap = append(swap,
+			u.valkeyClient.B().Rename().Key(stagingKey).Newkey(liveKey).Build(),
+			u.valkeyClient.B().Persist().Key(liveKey).Build(),
+		)
+	}
+	versionKey := providerKey(maliciousURLsVersionKey, provider)
+	if version == "" {
+		swap = append(swap, u.valkeyClient.B().Del().Key(versionKey).Build())
+	} else {
+		swap = append(swap, u.valkeyClient.B().Set().Key(versionKey).Value(version).Build())
+	}
+	fencingTokenKey := providerKey(maliciousURLsFencingTokenKey, provider)
+	swap = append(swap,
+		u.valkeyClient.B().Set().Key(providerKey(maliciousURLsLastUpdatedAtKey, provider)).Value(time.Now().UTC().Format(time.RFC3339)).Build(),
+		u.valkeyClient.B().Set().Key(fencingTokenKey).Value(strconv.FormatInt(fencingToken, 10)).Build(),
+		u.valkeyClient.B().Exec().Build(),
+	)
+
+	// The fencing token is watched, so that the transaction is aborted when a newer leader swaps the sets meanwhile.
+	err = u.valkeyClient.Dedicated(func(client valkey.DedicatedClient) error {
+		if err := client.Do(ctx, client.B().Watch().Key(fencingTokenKey).Build()).Error(); err != nil {
+			return err
+		}
+		lastFencingToken, err := client.Do(ctx, client.B().Get().Key(fencingTokenKey).Build()).AsInt64()
+		if err != nil && !valkey.IsValkeyNil(err) {
 			return err
 		}
-		lastUpdatedAtTime, err := time.Parse(time.RFC3339, lastUpdatedAt)
+		if lastFencingToken > fencingToken {
+			client.Do(ctx, client.B().Unwatch().Build())
+			return ErrStaleFencingToken
+		}
+
+		results := client.DoMulti(ctx, swap...)
+		for _, res := range results[:len(results)-1] {
+			if err = res.Error(); err != nil {
+				return err
+			}
+		}
+		// The errors of the queued commands are reported within the reply of EXEC, which is nil when aborted.
+		replies, err := results[len(results)-1].ToArray()
+		if valkey.IsValkeyNil(err) {
+			return ErrStaleFencingToken
+		}
 		if err != nil {
 			return err
 		}
-		elapsed := time.Since(lastUpdatedAtTime).Round(time.Minute)
-		if elapsed.Minutes() < 5 {
-			u.logger.WarnContext(ctx, fmt.Sprintf("The guardian's database has been updated %v ago (< 5m) - skipping..", elapsed))
-			return nil
+		for _, reply := range replies {
+			if err = reply.Error(); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
 	}
 
-	urls, err := u.urlhausClient.FetchAll(ctx)
-	if err != nil {
-		return err
+	return added, removed, nil
+}
+
+func (u *urlGuardian) Health(ctx context.Context) []model.ThreatProviderHealth {
+	healths := make([]model.ThreatProviderHealth, 0, len(u.providers))
+	for _, provider := range u.providers {
+		health := model.ThreatProviderHealth{Name: provider.Feed.Name(), Healthy: true}
+
+		lastUpdatedAt, err := u.lastUpdatedAt(ctx, provider)
+		if err != nil {
+			health.Healthy = false
+			health.LastError = err.Error()
+		}
+		health.LastUpdatedAt = lastUpdatedAt
+
+		entries, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Scard().Key(providerKey(maliciousURLsKey, provider)).Build()).AsInt64()
+		if err != nil {
+			health.Healthy = false
+			health.LastError = err.Error()
+		}
+		health.Entries = entries
+
+		u.mu.Lock()
+		if state, ok := u.states[health.Name]; ok {
+			health.LastAdded, health.LastRemoved = state.added, state.removed
+			health.TotalAdded, health.TotalRemoved = state.totalAdded, state.totalRemoved
+			if state.lastError != nil {
+				health.Healthy = false
+				health.LastError = state.lastError.Error()
+				lastErrorAt := state.lastErrorAt
+				health.LastErrorAt = &lastErrorAt
+			}
+		}
+		u.mu.Unlock()
+
+		// The feed is considered stale when it misses two consecutive refreshes.
+		if lastUpdatedAt == nil || time.Since(*lastUpdatedAt) > 2*provider.RefreshInterval+time.Minute {
+			health.Healthy = false
+		}
+
+		healths = append(healths, health)
 	}
 
-	for _, url := range urls {
-		u.valkeyClient.Do(ctx, u.valkeyClient.B().Sadd().Key(maliciousURLsKey).Member(url.URL).Build())
-		u.valkeyClient.Do(ctx, u.valkeyClient.B().Sadd().Key(maliciousURLsRefreshedKey).Member(url.URL).Build())
+	return healths
+}
+
+func (u *urlGuardian) Snapshot(ctx context.Context) ([]threatfeed.Entry, error) {
+	var entries []threatfeed.Entry
+	// The providers may report the same members.
+	seen := make(map[string]struct{})
+	for _, provider := range u.providers {
+		for g, setKey := range maliciousSetKeys {
+			key := providerKey(setKey, provider)
+			for cursor := uint64(0); ; {
+				scan, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Sscan().Key(key).Cursor(cursor).Count(1000).Build()).AsScanEntry()
+				if err != nil {
+					return nil, err
+				}
+				for _, member := range scan.Elements {
+					if _, ok := seen[setKey+member]; !ok {
+						seen[setKey+member] = struct{}{}
+						entries = append(entries, snapshotEntry(g, member))
+					}
+				}
+				if cursor = scan.Cursor; cursor == 0 {
+					break
+				}
+			}
+		}
 	}
 
-	u.valkeyClient.Do(ctx, u.valkeyClient.B().Set().Key(maliciousURLsLastUpdatedAtKey).Value(time.Now().UTC().Format(time.RFC3339)).Build())
+	return entries, nil
+}
 
-	staleURLs, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Sdiff().Key(maliciousURLsKey, maliciousURLsRefreshedKey).Build()).AsStrSlice()
+// snapshotEntry turns the member of the set back into entry, which is matched at the same granularity when loaded.
+// The scheme is irrelevant, as the members are matched regardless of it.
+func snapshotEntry(g granularity, member string) threatfeed.Entry {
+	switch g {
+	case granularityPathPrefix:
+		return threatfeed.Entry{URL: "http://" + member, Threat: "malware_download"}
+	case granularityHost:
+		return threatfeed.Entry{URL: "http://" + member + "/"}
+	case granularityDomain:
+		return threatfeed.Entry{URL: "http://" + member + "/", Tags: []string{"phishing"}}
+	default:
+		return threatfeed.Entry{URL: "http://" + member}
+	}
+}
+
+func (u *urlGuardian) lastUpdatedAt(ctx context.Context, provider ThreatProvider) (*time.Time, error) {
+	lastUpdatedAt, err := u.valkeyClient.Do(ctx, u.valkeyClient.B().Get().Key(providerKey(maliciousURLsLastUpdatedAtKey, provider)).Build()).ToString()
 	if err != nil {
-		u.logger.ErrorContext(ctx, "Error while determining stale URLs.", "err", err)
-		return err
+		if valkey.IsValkeyNil(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
 
-	// Cleanup stale URLs
-	for _, staleURL := range staleURLs {
-		u.valkeyClient.Do(ctx, u.valkeyClient.B().Srem().Key(maliciousURLsKey).Member(staleURL).Build())
+	lastUpdatedAtTime, err := time.Parse(time.RFC3339, lastUpdatedAt)
+	if err != nil {
+		return nil, err
 	}
-	u.logger.InfoContext(ctx, fmt.Sprintf("Deleted %d stale URLs.", len(staleURLs)))
 
-	u.valkeyClient.Do(ctx, u.valkeyClient.B().Del().Key(maliciousURLsRefreshedKey).Build())
+	return &lastUpdatedAtTime, nil
+}
+
+func (u *urlGuardian) recordError(provider ThreatProvider, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	state := u.state(provider)
+	state.lastError, state.lastErrorAt = err, time.Now().UTC()
+}
+
+func (u *urlGuardian) recordRefresh(provider ThreatProvider, added int64, removed int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	state := u.state(provider)
+	state.lastError, state.added, state.removed = nil, added, removed
+	state.totalAdded += added
+	state.totalRemoved += removed
+}
+
+// state returns the state of the provider, the caller has to hold the lock.
+func (u *urlGuardian) state(provider ThreatProvider) *threatProviderState {
+	state, ok := u.states[provider.Feed.Name()]
+	if !ok {
+		state = &threatProviderState{}
+		u.states[provider.Feed.Name()] = state
+	}
+
+	return state
+}
 
-	return nil
+// providerKey returns the key of the provider, the name of the provider is the hash tag of all its keys.
+func providerKey(key string, provider ThreatProvider) string {
+	return key + ":{" + provider.Feed.Name() + "}"
 }
 
-func NewURLGuardian(urlhausClient urlhaus.Client, valkeyClient valkey.Client, logger *slog.Logger) URLGuardian {
+// NewURLGuardian creates guardian checking URLs against the given providers. The resolver and the overrides are
+// optional, without them the redirects aren't followed and the feeds have the final say.
+func NewURLGuardian(providers []ThreatProvider, valkeyClient valkey.Client, resolver RedirectResolver, overrides GuardianOverrides, logger *slog.Logger) URLGuardian {
 	return &urlGuardian{
-		urlhausClient: urlhausClient,
-		valkeyClient:  valkeyClient,
-		logger:        logger,
+		providers:    providers,
+		valkeyClient: valkeyClient,
+		resolver:     resolver,
+		overrides:    overrides,
+		logger:       logger,
+		states:       make(map[string]*threatProviderState, len(providers)),
 	}
 }
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var errFeedUnavailable = errors.New("feed unavailable")
+
+// stubFeed serves the given entries or fails with the given error.
+type stubFeed struct {
+	name    string
+	entries []threatfeed.Entry
+	err     error
+}
+
+func (f *stubFeed) Name() string {
+	return f.name
+}
+
+func (f *stubFeed) Fetch(context.Context) ([]threatfeed.Entry, error) {
+	return f.entries, f.err
+}
+
+func newTestGuardian(t *testing.T, feeds ...*stubFeed) URLGuardian {
+	_, client := newTestValkey(t)
+	var providers []ThreatProvider
+	for _, feed := range feeds {
+		// Every update refreshes every provider.
+		providers = append(providers, ThreatProvider{Feed: feed})
+	}
+
+	return NewURLGuardian(providers, client, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestURLGuardianAnyProviderFlags(t *testing.T) {
+	urlhausFeed := &stubFeed{name: "urlhaus", entries: []threatfeed.Entry{{URL: "http://malware.example/payload.exe"}}}
+	openPhishFeed := &stubFeed{name: "openphish", entries: []threatfeed.Entry{{URL: "https://login.phish.example/signin"}}}
+	guardian := newTestGuardian(t, urlhausFeed, openPhishFeed)
+	ctx := context.Background()
+	urls := []string{"http://malware.example/payload.exe", "https://login.phish.example/signin", "https://example.com/"}
+
+	if _, err := guardian.UpdateDB(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	safe, err := guardian.SafeURLs(ctx, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, false, true}; !slices.Equal(safe, want) {
+		t.Errorf("got %v, want %v", safe, want)
+	}
+
+	t.Run("unavailable provider keeps its last entries", func(t *testing.T) {
+		openPhishFeed.entries, openPhishFeed.err = nil, errFeedUnavailable
+
+		refreshed, err := guardian.UpdateDB(ctx, 1)
+		if !errors.Is(err, errFeedUnavailable) {
+			t.Errorf("got %v, want %v", err, errFeedUnavailable)
+		}
+		if refreshed != 1 {
+			t.Errorf("got %d refreshed providers, want %d", refreshed, 1)
+		}
+
+		safe, err := guardian.SafeURLs(ctx, urls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []bool{false, false, true}; !slices.Equal(safe, want) {
+			t.Errorf("got %v, want %v", safe, want)
+		}
+	})
+}
+
+func TestURLGuardianProviderUnavailableFromStart(t *testing.T) {
+	guardian := newTestGuardian(t,
+		&stubFeed{name: "urlhaus", err: errFeedUnavailable},
+		&stubFeed{name: "openphish", entries: []threatfeed.Entry{{URL: "https://login.phish.example/signin"}}},
+	)
+	ctx := context.Background()
+
+	if _, err := guardian.UpdateDB(ctx, 1); !errors.Is(err, errFeedUnavailable) {
+		t.Errorf("got %v, want %v", err, errFeedUnavailable)
+	}
+
+	safe, err := guardian.SafeURLs(ctx, []string{"https://login.phish.example/signin", "https://example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, true}; !slices.Equal(safe, want) {
+		t.Errorf("got %v, want %v", safe, want)
+	}
+	for _, health := range guardian.Health(ctx) {
+		if health.Name == "urlhaus" && health.Healthy {
+			t.Errorf("got the unavailable provider healthy")
+		}
+	}
+}
+
+func TestURLGuardianIgnoresAppendedQuery(t *testing.T) {
+	guardian := newTestGuardian(t, &stubFeed{name: "urlhaus", entries: []threatfeed.Entry{
+		{URL: "http://evil.example/payload.exe", Threat: "malware_download"},
+		{URL: "http://evil.example/download.php?id=7", Threat: "malware_download"},
+	}})
+	ctx := context.Background()
+	if _, err := guardian.UpdateDB(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{
+		"http://evil.example/payload.exe?utm=1",
+		"https://evil.example/payload.exe",
+		"http://evil.example/download.php?id=7",
+		"http://evil.example/download.php?id=8",
+	}
+	safe, err := guardian.SafeURLs(ctx, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, false, false, true}; !slices.Equal(safe, want) {
+		t.Errorf("got %v, want %v", safe, want)
+	}
+}
+
+func TestURLGuardianDeletesLegacyKeys(t *testing.T) {
+	server, client := newTestValkey(t)
+	feed := &stubFeed{name: "urlhaus", entries: []threatfeed.Entry{{URL: "http://malware.example/1"}}}
+	guardian := NewURLGuardian([]ThreatProvider{{Feed: feed}}, client, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+	seed := func() {
+		server.SAdd("MaliciousURLs", "http://malware.example/0")
+		server.SAdd("MaliciousURLsRefreshed", "http://malware.example/0")
+		server.Set("MaliciousURLsLastUpdatedAt", "2024-01-01T00:00:00Z")
+	}
+
+	seed()
+	if _, err := guardian.UpdateDB(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range legacyKeys {
+		if server.Exists(key) {
+			t.Errorf("got legacy key %s kept", key)
+		}
+	}
+
+	// The keys are deleted only once, not by every refresh.
+	seed()
+	if _, err := guardian.UpdateDB(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range legacyKeys {
+		if !server.Exists(key) {
+			t.Errorf("got legacy key %s deleted again", key)
+		}
+	}
+}
+
+func TestURLGuardianSwapsSets(t *testing.T) {
+	server, client := newTestValkey(t)
+	feed := &stubFeed{name: "urlhaus", entries: []threatfeed.Entry{
+		{URL: "http://malware.example/1"},
+		{URL: "http://malware.example/2"},
+		{URL: "http://malware.example/3"},
+	}}
+	guardian := NewURLGuardian([]ThreatProvider{{Feed: feed}}, client, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+	urls := []string{"http://malware.example/1", "http://malware.example/2", "http://malware.example/4", "http://phish.example/login"}
+
+	assertRefresh := func(t *testing.T, wantSafe []bool, wantAdded, wantRemoved, wantTotalAdded, wantTotalRemoved int64) {
+		t.Helper()
+		safe, err := guardian.SafeURLs(ctx, urls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(safe, wantSafe) {
+			t.Errorf("got %v, want %v", safe, wantSafe)
+		}
+		health := guardian.Health(ctx)[0]
+		if health.LastAdded != wantAdded || health.LastRemoved != wantRemoved {
+			t.Errorf("got %d added and %d removed, want %d and %d", health.LastAdded, health.LastRemoved, wantAdded, wantRemoved)
+		}
+		if health.TotalAdded != wantTotalAdded || health.TotalRemoved != wantTotalRemoved {
+			t.Errorf("got %d added and %d removed in total, want %d and %d", health.TotalAdded, health.TotalRemoved, wantTotalAdded, wantTotalRemoved)
+		}
+		for _, key := range server.Keys() {
+			if strings.Contains(key, ":staging:") {
+				t.Errorf("got staging key %s left behind", key)
+			}
+		}
+	}
+
+	if _, err := guardian.UpdateDB(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	assertRefresh(t, []bool{false, false, true, true}, 3, 0, 3, 0)
+
+	t.Run("refresh diffs the sets", func(t *testing.T) {
+		// The root of the host is reported into the host set as well.
+		feed.entries = []threatfeed.Entry{
+			{URL: "http://malware.example/2"},
+			{URL: "http://malware.example/3"},
+			{URL: "http://malware.example/4"},
+			{URL: "http://phish.example/"},
+		}
+		if _, err := guardian.UpdateDB(ctx, 2); err != nil {
+			t.Fatal(err)
+		}
+		assertRefresh(t, []bool{true, false, false, false}, 3, 1, 6, 1)
+	})
+
+	t.Run("empty feed keeps the live sets", func(t *testing.T) {
+		feed.entries = nil
+		if _, err := guardian.UpdateDB(ctx, 2); !errors.Is(err, ErrEmptyFeed) {
+			t.Errorf("got %v, want %v", err, ErrEmptyFeed)
+		}
+		assertRefresh(t, []bool{true, false, false, false}, 3, 1, 6, 1)
+	})
+
+	t.Run("stale leader is refused", func(t *testing.T) {
+		feed.entries = []threatfeed.Entry{{URL: "http://malware.example/1"}}
+		if _, err := guardian.UpdateDB(ctx, 1); !errors.Is(err, ErrStaleFencingToken) {
+			t.Errorf("got %v, want %v", err, ErrStaleFencingToken)
+		}
+		assertRefresh(t, []bool{true, false, false, false}, 3, 1, 6, 1)
+	})
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
+	"golang.org/x/net/publicsuffix"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// granularity determines how much of the URL reported by a threat feed is blocked.
+type granularity int
+
+const (
+	// granularityExact blocks the URL only.
+	granularityExact granularity = iota
+	// granularityPathPrefix blocks the URLs within the same directory of the host.
+	granularityPathPrefix
+	// granularityHost blocks the whole host.
+	granularityHost
+	// granularityDomain blocks the registrable domain including all its subdomains.
+	granularityDomain
+)
+
+// hostTags are tags of malware families which are distributed from dedicated hosts, typically bare IPs of
+// compromised devices.
+var hostTags = map[string]struct{}{
+	"32-bit":   {},
+	"arm":      {},
+	"bashlite": {},
+	"elf":      {},
+	"gafgyt":   {},
+	"hajime":   {},
+	"mips":     {},
+	"mirai":    {},
+	"mozi":     {},
+}
+
+// domainTags are tags of threats which are typically served from domains registered by the attackers.
+var domainTags = map[string]struct{}{
+	"phishing": {},
+	"scam":     {},
+}
+
+// sharedDomains are registrable domains of platforms hosting content of many unrelated users. Reports of their
+// URLs never block more than the URL itself. Platforms giving each user own subdomain of a public suffix,
+// e.g. github.io, need not be listed as their registrable domains are already per user.
+var sharedDomains = map[string]struct{}{
+	"1drv.ms":                {},
+	"amazonaws.com":          {},
+	"bit.ly":                 {},
+	"bitbucket.org":          {},
+	"cloudfront.net":         {},
+	"discord.com":            {},
+	"discordapp.com":         {},
+	"dropbox.com":            {},
+	"dropboxusercontent.com": {},
+	"github.com":             {},
+	"githubusercontent.com":  {},
+	"gitlab.com":             {},
+	"google.com":             {},
+	"googleapis.com":         {},
+	"googleusercontent.com":  {},
+	"live.com":               {},
+	"mediafire.com":          {},
+	"pastebin.com":           {},
+	"sharepoint.com":         {},
+	"tinyurl.com":            {},
+	"transfer.sh":            {},
+}
+
+// urlMatchKeys holds the keys under which the URL is looked up at each granularity.
+type urlMatchKeys struct {
+	exact string
+	// exactPath is the exact key without the query.
+	exactPath    string
+	pathPrefixes []string
+	host         string
+	domain       string
+	ip           bool
+}
+
+// matchKeys canonicalizes the URL and derives its match keys. The scheme is dropped, so that reports of http URL
+// block also its https counterpart and vice versa.
+func matchKeys(rawURL string) (urlMatchKeys, error) {
+	normalized, err := normalizeURL(rawURL)
+	if err != nil {
+		return urlMatchKeys{}, err
+	}
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return urlMatchKeys{}, err
+	}
+
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if strings.Contains(host, ":") {
+		// IPv6 addresses are kept in brackets, so that they can't be confused with the port.
+		host = "[" + host + "]"
+	}
+	if u.Port() != "" {
+		host = host + ":" + u.Port()
+	}
+	cleanPath := path.Clean("/" + u.EscapedPath())
+	if strings.HasSuffix(u.EscapedPath(), "/") && cleanPath != "/" {
+		cleanPath += "/"
+	}
+
+	keys := urlMatchKeys{host: host, exactPath: host + cleanPath}
+	keys.exact = keys.exactPath
+	if u.RawQuery != "" {
+		keys.exact += "?" + u.RawQuery
+	}
+
+	// The path prefixes are the directories of the path from the outermost to the innermost e.g. /a/ and /a/b/.
+	dir := cleanPath[:strings.LastIndex(cleanPath, "/")+1]
+	for i := 1; i < len(dir); i++ {
+		if dir[i] == '/' {
+			keys.pathPrefixes = append(keys.pathPrefixes, host+dir[:i+1])
+		}
+	}
+
+	if _, err := netip.ParseAddr(strings.Trim(u.Hostname(), "[]")); err == nil {
+		keys.ip = true
+		return keys, nil
+	}
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(u.Hostname(), ".")); err == nil {
+		keys.domain = domain
+	}
+
+	return keys, nil
+}
+
+// exactKeys returns the exact key along with the one without the query, so that appending query to the reported URL
+// doesn't get around the report.
+func (k urlMatchKeys) exactKeys() []string {
+	if k.exact == k.exactPath {
+		return []string{k.exact}
+	}
+
+	return []string{k.exact, k.exactPath}
+}
+
+// entryGranularity decides how much of the reported URL to block based on its threat and tags.
+func entryGranularity(entry threatfeed.Entry, keys urlMatchKeys) granularity {
+	if sharedHost(keys.host) {
+		return granularityExact
+	}
+
+	g := granularityExact
+	if entry.Threat == "malware_download" && len(keys.pathPrefixes) > 0 {
+		g = granularityPathPrefix
+	}
+	// Reports of bare IPs or of the root of the host are taken as the whole host being malicious.
+	if keys.ip || keys.exact == keys.host+"/" {
+		g = granularityHost
+	}
+	for _, tag := range entry.Tags {
+		tag = strings.ToLower(tag)
+		if _, ok := hostTags[tag]; ok {
+			g = max(g, granularityHost)
+		}
+		if _, ok := domainTags[tag]; ok && !keys.ip {
+			g = max(g, granularityDomain)
+		}
+	}
+
+	return g
+}
+
+// sharedHost reports whether the host belongs to one of the shared domains. The suffixes are compared instead of the
+// registrable domain, because the public suffix list treats some of the shared hosts as suffixes themselves.
+func sharedHost(host string) bool {
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	for {
+		if _, ok := sharedDomains[host]; ok {
+			return true
+		}
+		i := strings.Index(host, ".")
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
+	"slices"
+	"testing"
+)
+
+func TestMatchKeys(t *testing.T) {
+	keys, err := matchKeys("HTTPS://Login.Example.co.uk:8443/a/./b/c.exe?x=1#top")
+	if err != nil {
+		t.Fatalf("matchKeys returned error: %v", err)
+	}
+	if keys.exact != "login.example.co.uk:8443/a/b/c.exe?x=1" {
+		t.Errorf("exact = %q", keys.exact)
+	}
+	if want := []string{"login.example.co.uk:8443/a/b/c.exe?x=1", "login.example.co.uk:8443/a/b/c.exe"}; !slices.Equal(keys.exactKeys(), want) {
+		t.Errorf("exactKeys = %q, want %q", keys.exactKeys(), want)
+	}
+	if want := []string{"login.example.co.uk:8443/a/", "login.example.co.uk:8443/a/b/"}; !slices.Equal(keys.pathPrefixes, want) {
+		t.Errorf("pathPrefixes = %q, want %q", keys.pathPrefixes, want)
+	}
+	if keys.host != "login.example.co.uk:8443" {
+		t.Errorf("host = %q", keys.host)
+	}
+	if keys.domain != "example.co.uk" {
+		t.Errorf("domain = %q", keys.domain)
+	}
+
+	httpKeys, _ := matchKeys("http://login.example.co.uk:8443/a/b/c.exe?x=1")
+	if httpKeys.exact != keys.exact {
+		t.Errorf("exact keys of http and https URLs differ: %q and %q", httpKeys.exact, keys.exact)
+	}
+}
+
+func TestEntryGranularity(t *testing.T) {
+	tests := []struct {
+		entry threatfeed.Entry
+		want  granularity
+	}{
+		{threatfeed.Entry{URL: "http://evil.example/a/b/payload.exe", Threat: "malware_download"}, granularityPathPrefix},
+		{threatfeed.Entry{URL: "http://evil.example/payload.exe", Threat: "malware_download"}, granularityExact},
+		{threatfeed.Entry{URL: "http://evil.example/"}, granularityHost},
+		{threatfeed.Entry{URL: "http://192.0.2.1/a/b.exe"}, granularityHost},
+		{threatfeed.Entry{URL: "http://login.evil.example/signin", Tags: []string{"Phishing"}}, granularityDomain},
+		{threatfeed.Entry{URL: "http://192.0.2.1/signin", Tags: []string{"phishing"}}, granularityHost},
+		{threatfeed.Entry{URL: "https://raw.githubusercontent.com/u/r/main/x.sh", Threat: "malware_download", Tags: []string{"phishing"}}, granularityExact},
+	}
+	for _, tt := range tests {
+		keys, err := matchKeys(tt.entry.URL)
+		if err != nil {
+			t.Fatalf("matchKeys(%q) returned error: %v", tt.entry.URL, err)
+		}
+		if got := entryGranularity(tt.entry, keys); got != tt.want {
+			t.Errorf("entryGranularity(%q) = %d, want %d", tt.entry.URL, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotEntry(t *testing.T) {
+	tests := map[string]granularity{
+		"evil.example/a/b/payload.exe?x=1": granularityExact,
+		"evil.example/a/b/":                granularityPathPrefix,
+		"[2001:db8::1]:8080":               granularityHost,
+		"evil.example":                     granularityDomain,
+	}
+	for member, g := range tests {
+		entry := snapshotEntry(g, member)
+		keys, err := matchKeys(entry.URL)
+		if err != nil {
+			t.Fatalf("matchKeys(%q) returned error: %v", entry.URL, err)
+		}
+		if got := entryGranularity(entry, keys); got != g {
+			t.Errorf("entryGranularity(%q) = %d, want %d", entry.URL, got, g)
+		}
+	}
+}
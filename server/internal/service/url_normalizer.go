@@ -0,0 +1,52 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// normalizeURL brings the URL to a form in which equivalent URLs are equal. Only transformations which preserve
+// the semantics are applied: lowercasing the scheme and the host, dropping the default port, the fragment and
+// the empty query and using "/" for the empty path.
+func normalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host = host + ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// hashURL hashes the normalized URL together with its owner, so that deduplication never crosses owners.
+func hashURL(ownerId string, rawURL string) (string, error) {
+	normalized, err := normalizeURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(ownerId + "\n" + normalized))
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://www.fsf.org/blogs":                "https://www.fsf.org/blogs",
+		"HTTPS://WWW.FSF.ORG/blogs":                "https://www.fsf.org/blogs",
+		"https://www.fsf.org:443/blogs#community":  "https://www.fsf.org/blogs",
+		"http://www.fsf.org:80":                    "http://www.fsf.org/",
+		"http://www.fsf.org:8080/blogs?":           "http://www.fsf.org:8080/blogs",
+		"https://www.fsf.org/Blogs?b=2&a=1":        "https://www.fsf.org/Blogs?b=2&a=1",
+		"https://[2001:DB8::1]:443/blogs":          "https://[2001:db8::1]/blogs",
+		"  https://www.fsf.org/blogs/community/  ": "https://www.fsf.org/blogs/community/",
+	}
+	for rawURL, want := range tests {
+		got, err := normalizeURL(rawURL)
+		if err != nil {
+			t.Fatalf("normalizeURL(%q) returned error: %v", rawURL, err)
+		}
+		if got != want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
+
+func TestHashURL(t *testing.T) {
+	hash := func(ownerId string, rawURL string) string {
+		t.Helper()
+		urlHash, err := hashURL(ownerId, rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return urlHash
+	}
+
+	want := hash("owner-1", "https://www.fsf.org/blogs")
+	for _, equivalent := range []string{"HTTPS://WWW.FSF.ORG/blogs", "https://www.fsf.org:443/blogs#community", " https://www.fsf.org/blogs?"} {
+		if got := hash("owner-1", equivalent); got != want {
+			t.Errorf("hashURL(%q) = %s, want the hash of the normalized URL %s", equivalent, got, want)
+		}
+	}
+	if got := hash("owner-2", "https://www.fsf.org/blogs"); got == want {
+		t.Errorf("got the same hash for another owner, want the hashes scoped per owner")
+	}
+	if got := hash("", "https://www.fsf.org/blogs"); got == want {
+		t.Errorf("got the same hash for anonymous owner, want the hashes scoped per owner")
+	}
+	if got := hash("owner-1", "https://www.fsf.org/Blogs"); got == want {
+		t.Errorf("got the same hash for another path, want the paths compared case-sensitively")
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"log/slog"
+	"time"
+)
+
+// URLReaper archives shortened URLs which have been expired for longer than the retention period.
+// Until they are archived the expired shortened URLs are resolved as gone rather than not found.
+type URLReaper interface {
+	Reap(ctx context.Context) error
+}
+
+type urlReaper struct {
+	store     store.ShortenedURL
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+func (r *urlReaper) Reap(ctx context.Context) error {
+	archived, err := r.store.ArchiveExpired(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		return err
+	}
+	r.logger.InfoContext(ctx, fmt.Sprintf("Archived %d expired URLs.", archived))
+
+	return nil
+}
+
+func NewURLReaper(store store.ShortenedURL, retention time.Duration, logger *slog.Logger) URLReaper {
+	return &urlReaper{
+		store:     store,
+		retention: retention,
+		logger:    logger,
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"log/slog"
+)
+
+// URLRescanner re-checks the original URLs of the stored shortened URLs against the guardian and quarantines the
+// ones which have been reported as malicious since they were shortened.
+type URLRescanner interface {
+	Rescan(ctx context.Context) error
+}
+
+type urlRescanner struct {
+	store     store.ShortenedURL
+	guardian  URLGuardian
+	batchSize int
+	logger    *slog.Logger
+}
+
+func (r *urlRescanner) Rescan(ctx context.Context) error {
+	var afterId int64
+	var scanned, quarantined int
+	for {
+		shortenedURLs, err := r.store.Scan(ctx, afterId, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(shortenedURLs) == 0 {
+			break
+		}
+
+		urls := make([]string, len(shortenedURLs))
+		for i, shortenedURL := range shortenedURLs {
+			urls[i] = shortenedURL.OriginalURL
+		}
+		safe, err := r.guardian.SafeURLs(ctx, urls)
+		if err != nil {
+			return err
+		}
+
+		var malicious []*model.ShortenedURL
+		for i, shortenedURL := range shortenedURLs {
+			if !safe[i] {
+				malicious = append(malicious, shortenedURL)
+			}
+		}
+		if len(malicious) > 0 {
+			if err = r.store.Quarantine(ctx, malicious); err != nil {
+				return err
+			}
+			for _, shortenedURL := range malicious {
+				r.logger.WarnContext(ctx, "Quarantined shortened URL.", "slug", shortenedURL.Slug, "url", shortenedURL.OriginalURL)
+			}
+		}
+
+		scanned += len(shortenedURLs)
+		quarantined += len(malicious)
+		afterId = shortenedURLs[len(shortenedURLs)-1].Id
+		if len(shortenedURLs) < r.batchSize {
+			break
+		}
+	}
+	r.logger.InfoContext(ctx, fmt.Sprintf("Rescanned %d URLs, quarantined %d.", scanned, quarantined))
+
+	return nil
+}
+
+func NewURLRescanner(store store.ShortenedURL, guardian URLGuardian, batchSize int, logger *slog.Logger) URLRescanner {
+	return &urlRescanner{
+		store:     store,
+		guardian:  guardian,
+		batchSize: batchSize,
+		logger:    logger,
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/threatfeed"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// Scan pages through the shortened URLs which haven't been quarantined yet, the same way the Postgres store does.
+func (s *stubShortenedURLStore) Scan(_ context.Context, afterId int64, limit int) ([]*model.ShortenedURL, error) {
+	var shortenedURLs []*model.ShortenedURL
+	for _, shortenedURL := range s.shortenedURLs {
+		if shortenedURL.Id > afterId && !shortenedURL.Quarantined() && len(shortenedURLs) < limit {
+			shortenedURLs = append(shortenedURLs, shortenedURL)
+		}
+	}
+
+	return shortenedURLs, nil
+}
+
+func (s *stubShortenedURLStore) Quarantine(_ context.Context, shortenedURLs []*model.ShortenedURL) error {
+	now := time.Now()
+	for _, shortenedURL := range shortenedURLs {
+		shortenedURL.QuarantinedAt = &now
+	}
+
+	return nil
+}
+
+func TestURLRescannerRescan(t *testing.T) {
+	feed := &stubFeed{name: "urlhaus", entries: []threatfeed.Entry{{URL: "http://malware.example/payload.exe"}}}
+	guardian := newTestGuardian(t, feed)
+	ctx := context.Background()
+	// The links are shortened before the guardian has loaded any feed.
+	stub := &stubShortenedURLStore{shortenedURLs: []*model.ShortenedURL{
+		{Id: 1, Slug: "docs", OriginalURL: "https://example.com/docs"},
+		{Id: 2, Slug: "blog", OriginalURL: "https://example.com/blog"},
+		{Id: 3, Slug: "payload", OriginalURL: "http://malware.example/payload.exe"},
+	}}
+	// The batch smaller than the number of links makes the rescanner page through them.
+	rescanner := NewURLRescanner(stub, guardian, 2, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := rescanner.Rescan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, shortenedURL := range stub.shortenedURLs {
+		if shortenedURL.Quarantined() {
+			t.Errorf("%s is quarantined before the initial load", shortenedURL.Slug)
+		}
+	}
+
+	// The initial load counts as refresh, which is what triggers the rescan after it.
+	refreshed, err := guardian.UpdateDB(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed != 1 {
+		t.Errorf("got %d refreshed providers, want 1", refreshed)
+	}
+	if err := rescanner.Rescan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, shortenedURL := range stub.shortenedURLs {
+		if want := shortenedURL.Slug == "payload"; shortenedURL.Quarantined() != want {
+			t.Errorf("%s quarantined: got %v, want %v", shortenedURL.Slug, shortenedURL.Quarantined(), want)
+		}
+	}
+}
@@ -2,30 +2,67 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/aboyadzhiev/snip/server/internal/model"
 	"github.com/aboyadzhiev/snip/server/internal/store"
 	"github.com/jxskiss/base62"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 )
 
 var ErrMaliciousURLDetected = errors.New("malicious URL detected")
-var ErrIllegalSlug = errors.New("the given slug doesn't represent base62 encoded integer value")
+var ErrIllegalSlug = errors.New("the given slug contains illegal characters")
+var ErrSlugAlreadyTaken = errors.New("the given slug is already taken")
+var ErrShortenedURLExpired = errors.New("the shortened URL has expired")
+var ErrShortenedURLDeleted = errors.New("the shortened URL has been deleted")
+var ErrShortenedURLQuarantined = errors.New("the shortened URL has been quarantined")
+var ErrIllegalCursor = errors.New("the given cursor is illegal")
+var ErrUnsupportedInBatch = errors.New("custom slugs and deduplication are not supported in batches")
+
+// maxGeneratedSlugAttempts limits how many sequence IDs are tried when a generated slug clashes with a custom one
+// or the ID clashes with an existing one.
+const maxGeneratedSlugAttempts = 3
+
+// policyCheckConcurrency limits how many destinations of a batch are checked, and so resolved, at once.
+const policyCheckConcurrency = 16
+
+const defaultListLimit = 50
+const maxListLimit = 200
 
 type URLShortener interface {
-	Shorten(ctx context.Context, url string) (string, error)
+	Shorten(ctx context.Context, shortenURLReq model.ShortenURLReq) (string, error)
+	// ShortenBatch shortens the given URLs at once and returns result per URL. The error is returned only when none
+	// of them could be shortened.
+	ShortenBatch(ctx context.Context, shortenURLReqs []model.ShortenURLReq) ([]model.ShortenResult, error)
 	Resolve(ctx context.Context, slug string) (string, error)
+	// List returns a page of the shortened URLs owned by the given owner.
+	List(ctx context.Context, ownerId string, listReq model.ListShortenedURLsReq) (*model.ShortenedURLListRes, error)
+	Get(ctx context.Context, ownerId string, slug string) (*model.ShortenedURLRes, error)
+	Update(ctx context.Context, ownerId string, slug string, updateReq model.UpdateShortenedURLReq) (*model.ShortenedURLRes, error)
+	Delete(ctx context.Context, ownerId string, slug string) error
 }
 
 type urlShortener struct {
 	hostname string
 	sequence store.ShortenedURLSequence
 	store    store.ShortenedURL
-	guardian URLGuardian
+	// redirects serves the lookups of Resolve, which may read from the replicas unlike the store.
+	redirects  store.ShortenedURL
+	guardian   URLGuardian
+	policy     DestinationPolicy
+	obfuscator IDObfuscator
 }
 
-func (s *urlShortener) Shorten(ctx context.Context, url string) (string, error) {
-	safeURL, err := s.guardian.SafeURL(ctx, url)
+func (s *urlShortener) Shorten(ctx context.Context, shortenURLReq model.ShortenURLReq) (string, error) {
+	if err := s.policy.Check(ctx, shortenURLReq.URL); err != nil {
+		return "", err
+	}
+
+	safeURL, err := s.guardian.SafeURL(ctx, shortenURLReq.URL)
 	if err != nil {
 		return "", err
 	}
@@ -34,44 +71,423 @@ func (s *urlShortener) Shorten(ctx context.Context, url string) (string, error)
 		return "", ErrMaliciousURLDetected
 	}
 
-	id, err := s.sequence.NextId(ctx)
+	var urlHash string
+	if shortenURLReq.Deduplicate {
+		urlHash, err = hashURL(shortenURLReq.OwnerId, shortenURLReq.URL)
+		if err != nil {
+			return "", err
+		}
+		shortenedURL, err := s.store.FindByURLHash(ctx, urlHash)
+		if err == nil {
+			return s.shortURL(shortenedURL), nil
+		}
+		if !errors.Is(err, store.ErrShortenedURLNotFound) {
+			return "", err
+		}
+	}
+
+	shortenedURL := &model.ShortenedURL{
+		Slug:        shortenURLReq.Slug,
+		OriginalURL: shortenURLReq.URL,
+		ExpiresAt:   shortenURLReq.ExpirationTime(time.Now()),
+		URLHash:     urlHash,
+		OwnerId:     shortenURLReq.OwnerId,
+	}
+
+	err = s.save(ctx, shortenedURL)
+	if errors.Is(err, store.ErrURLHashAlreadyTaken) {
+		// A concurrent request has shortened the same URL in the meantime.
+		shortenedURL, err = s.store.FindByURLHash(ctx, urlHash)
+	}
 	if err != nil {
 		return "", err
 	}
-	slug := string(base62.FormatInt(id))
-	shortenedURL := &model.ShortenedURL{
-		Id:          id,
-		Slug:        slug,
-		OriginalURL: url,
+
+	return s.shortURL(shortenedURL), nil
+}
+
+func (s *urlShortener) ShortenBatch(ctx context.Context, shortenURLReqs []model.ShortenURLReq) ([]model.ShortenResult, error) {
+	results := make([]model.ShortenResult, len(shortenURLReqs))
+
+	urls := make([]string, len(shortenURLReqs))
+	for i, shortenURLReq := range shortenURLReqs {
+		urls[i] = shortenURLReq.URL
+	}
+	safe, err := s.guardian.SafeURLs(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Batches tend to repeat the destinations, so the policy is checked once per scheme, host and port.
+	origins := make(map[string]string)
+	for _, shortenURLReq := range shortenURLReqs {
+		if shortenURLReq.Slug == "" && !shortenURLReq.Deduplicate {
+			origins[urlOrigin(shortenURLReq.URL)] = shortenURLReq.URL
+		}
+	}
+	violations := s.checkOrigins(ctx, origins)
+
+	now := time.Now()
+	shortenedURLs := make([]*model.ShortenedURL, 0, len(shortenURLReqs))
+	indexes := make([]int, 0, len(shortenURLReqs))
+	for i, shortenURLReq := range shortenURLReqs {
+		if shortenURLReq.Slug != "" || shortenURLReq.Deduplicate {
+			results[i].Err = ErrUnsupportedInBatch
+			continue
+		}
+		if violation := violations[urlOrigin(shortenURLReq.URL)]; violation != nil {
+			results[i].Err = violation
+			continue
+		}
+		if !safe[i] {
+			results[i].Err = ErrMaliciousURLDetected
+			continue
+		}
+		shortenedURLs = append(shortenedURLs, &model.ShortenedURL{
+			OriginalURL: shortenURLReq.URL,
+			ExpiresAt:   shortenURLReq.ExpirationTime(now),
+			OwnerId:     shortenURLReq.OwnerId,
+		})
+		indexes = append(indexes, i)
+	}
+
+	if len(shortenedURLs) == 0 {
+		return results, nil
+	}
+
+	ids, err := s.sequence.NextIds(ctx, len(shortenedURLs))
+	if err != nil {
+		return nil, err
+	}
+	for i, shortenedURL := range shortenedURLs {
+		shortenedURL.Id = ids[i]
+		if shortenedURL.Slug, err = s.generateSlug(ids[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.store.SaveAll(ctx, shortenedURLs)
+	if errors.Is(err, store.ErrSlugAlreadyTaken) || errors.Is(err, store.ErrIdAlreadyTaken) {
+		// Some of the generated slugs have been claimed as custom slugs or the sequence fell behind,
+		// so fall back to saving one by one.
+		for i, shortenedURL := range shortenedURLs {
+			shortenedURL.Slug = ""
+			if err := s.save(ctx, shortenedURL); err != nil {
+				results[indexes[i]].Err = err
+				continue
+			}
+			results[indexes[i]].ShortenURL = s.shortURL(shortenedURL)
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, shortenedURL := range shortenedURLs {
+		results[indexes[i]].ShortenURL = s.shortURL(shortenedURL)
 	}
 
-	err = s.store.Save(ctx, shortenedURL)
+	return results, nil
+}
+
+// save assigns the next ID to the shortened URL and saves it. The slug is generated from the ID unless it's
+// a custom one.
+func (s *urlShortener) save(ctx context.Context, shortenedURL *model.ShortenedURL) error {
+	generatedSlug := shortenedURL.Slug == ""
+	for attempt := 1; ; attempt++ {
+		id, err := s.sequence.NextId(ctx)
+		if err != nil {
+			return err
+		}
+
+		shortenedURL.Id = id
+		if generatedSlug {
+			if shortenedURL.Slug, err = s.generateSlug(id); err != nil {
+				return err
+			}
+		}
+
+		err = s.store.Save(ctx, shortenedURL)
+		if errors.Is(err, store.ErrSlugAlreadyTaken) {
+			if !generatedSlug {
+				return ErrSlugAlreadyTaken
+			}
+			// The generated slug has been claimed as a custom slug, so try with the next ID.
+			if attempt < maxGeneratedSlugAttempts {
+				continue
+			}
+		}
+		if errors.Is(err, store.ErrIdAlreadyTaken) && attempt < maxGeneratedSlugAttempts {
+			// The sequence fell behind the saved shortened URLs e.g. because Valkey lost its data.
+			if err = s.reconcileSequence(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return err
+	}
+}
+
+// generateSlug encodes the obfuscated ID.
+func (s *urlShortener) generateSlug(id int64) (string, error) {
+	obfuscatedId, err := s.obfuscator.Obfuscate(id)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s/%s", s.hostname, shortenedURL.Slug), nil
+	return string(base62.FormatInt(obfuscatedId)), nil
 }
 
-func (s *urlShortener) Resolve(ctx context.Context, slug string) (string, error) {
-	id, err := base62.ParseInt([]byte(slug))
+// findGenerated finds the shortened URL by the ID the slug has been generated from. Slugs which can't have been
+// generated, including the custom ones equal to generated slug of another ID, are reported as not found.
+func (s *urlShortener) findGenerated(ctx context.Context, slug string) (*model.ShortenedURL, error) {
+	obfuscatedId, err := base62.ParseInt([]byte(slug))
+	if err != nil || string(base62.FormatInt(obfuscatedId)) != slug {
+		return nil, store.ErrShortenedURLNotFound
+	}
+	id, err := s.obfuscator.Deobfuscate(obfuscatedId)
+	if err != nil {
+		return nil, store.ErrShortenedURLNotFound
+	}
+
+	shortenedURL, err := s.redirects.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if shortenedURL.Slug != slug {
+		return nil, store.ErrShortenedURLNotFound
+	}
+
+	return shortenedURL, nil
+}
+
+func (s *urlShortener) reconcileSequence(ctx context.Context) error {
+	maxId, err := s.store.MaxId(ctx)
 	if err != nil {
+		return err
+	}
+
+	return s.sequence.Reconcile(ctx, maxId)
+}
+
+func (s *urlShortener) shortURL(shortenedURL *model.ShortenedURL) string {
+	return fmt.Sprintf("%s/%s", s.hostname, shortenedURL.Slug)
+}
+
+func (s *urlShortener) Resolve(ctx context.Context, slug string) (string, error) {
+	if !model.ValidSlug(slug) {
 		return "", ErrIllegalSlug
 	}
 
-	shortenedURL, err := s.store.Find(ctx, id)
+	shortenedURL, err := s.findGenerated(ctx, slug)
+	if errors.Is(err, store.ErrShortenedURLNotFound) {
+		// The slug is custom one.
+		shortenedURL, err = s.redirects.FindBySlug(ctx, slug)
+	}
 	if err != nil {
 		return "", err
 	}
 
+	if shortenedURL.Deleted() {
+		return "", ErrShortenedURLDeleted
+	}
+
+	if shortenedURL.Expired(time.Now()) {
+		return "", ErrShortenedURLExpired
+	}
+
+	if shortenedURL.Quarantined() {
+		return "", ErrShortenedURLQuarantined
+	}
+
 	return shortenedURL.OriginalURL, nil
 }
 
-func NewURLShortener(hostname string, sequence store.ShortenedURLSequence, store store.ShortenedURL, guardian URLGuardian) URLShortener {
+func (s *urlShortener) List(ctx context.Context, ownerId string, listReq model.ListShortenedURLsReq) (*model.ShortenedURLListRes, error) {
+	filter := model.ShortenedURLFilter{
+		OwnerId:       ownerId,
+		URLContains:   listReq.URLContains,
+		CreatedAfter:  listReq.CreatedAfter,
+		CreatedBefore: listReq.CreatedBefore,
+		Limit:         listReq.Limit,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	filter.Limit = min(filter.Limit, maxListLimit)
+
+	if listReq.Cursor != "" {
+		beforeId, err := decodeCursor(listReq.Cursor)
+		if err != nil {
+			return nil, ErrIllegalCursor
+		}
+		filter.BeforeId = beforeId
+	}
+
+	shortenedURLs, err := s.store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	listRes := &model.ShortenedURLListRes{Items: make([]*model.ShortenedURLRes, 0, len(shortenedURLs))}
+	for _, shortenedURL := range shortenedURLs {
+		listRes.Items = append(listRes.Items, s.shortenedURLRes(shortenedURL))
+	}
+	if len(shortenedURLs) == filter.Limit {
+		listRes.NextCursor = encodeCursor(shortenedURLs[len(shortenedURLs)-1].Id)
+	}
+
+	return listRes, nil
+}
+
+func (s *urlShortener) Get(ctx context.Context, ownerId string, slug string) (*model.ShortenedURLRes, error) {
+	shortenedURL, err := s.findOwned(ctx, ownerId, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.shortenedURLRes(shortenedURL), nil
+}
+
+func (s *urlShortener) Update(ctx context.Context, ownerId string, slug string, updateReq model.UpdateShortenedURLReq) (*model.ShortenedURLRes, error) {
+	shortenedURL, err := s.findOwned(ctx, ownerId, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if updateReq.URL != nil && *updateReq.URL != shortenedURL.OriginalURL {
+		if err = s.policy.Check(ctx, *updateReq.URL); err != nil {
+			return nil, err
+		}
+
+		safeURL, err := s.guardian.SafeURL(ctx, *updateReq.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		if !safeURL {
+			return nil, ErrMaliciousURLDetected
+		}
+
+		shortenedURL.OriginalURL = *updateReq.URL
+		// The hash no longer matches the original URL, so the shortened URL is excluded from deduplication.
+		// The new URL has just been checked, so the store releases the shortened URL from the quarantine.
+		shortenedURL.URLHash = ""
+	}
+
+	if expiresAt := updateReq.ExpirationTime(time.Now()); expiresAt != nil {
+		shortenedURL.ExpiresAt = expiresAt
+		// Deduplication never hands out expiring shortened URLs.
+		shortenedURL.URLHash = ""
+	}
+
+	if err = s.store.Update(ctx, shortenedURL); err != nil {
+		return nil, err
+	}
+
+	return s.shortenedURLRes(shortenedURL), nil
+}
+
+func (s *urlShortener) Delete(ctx context.Context, ownerId string, slug string) error {
+	shortenedURL, err := s.findOwned(ctx, ownerId, slug)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Delete(ctx, shortenedURL)
+}
+
+// findOwned returns not deleted shortened URL of the given owner.
+func (s *urlShortener) findOwned(ctx context.Context, ownerId string, slug string) (*model.ShortenedURL, error) {
+	if !model.ValidSlug(slug) {
+		return nil, ErrIllegalSlug
+	}
+
+	shortenedURL, err := s.store.FindBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if shortenedURL.Deleted() {
+		return nil, store.ErrShortenedURLNotFound
+	}
+
+	if shortenedURL.OwnerId == "" || shortenedURL.OwnerId != ownerId {
+		return nil, ErrNotOwner
+	}
+
+	return shortenedURL, nil
+}
+
+func (s *urlShortener) shortenedURLRes(shortenedURL *model.ShortenedURL) *model.ShortenedURLRes {
+	return &model.ShortenedURLRes{
+		Slug:        shortenedURL.Slug,
+		ShortenURL:  s.shortURL(shortenedURL),
+		URL:         shortenedURL.OriginalURL,
+		CreatedAt:   shortenedURL.CreatedAt,
+		ExpiresAt:   shortenedURL.ExpiresAt,
+		Quarantined: shortenedURL.Quarantined(),
+	}
+}
+
+// checkOrigins checks the policy of the given URLs, keyed by their origins, at most policyCheckConcurrency at once and
+// returns the violations by the origins.
+func (s *urlShortener) checkOrigins(ctx context.Context, origins map[string]string) map[string]error {
+	violations := make(map[string]error, len(origins))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, policyCheckConcurrency)
+	for origin, rawURL := range origins {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			violation := s.policy.Check(ctx, rawURL)
+
+			mu.Lock()
+			defer mu.Unlock()
+			violations[origin] = violation
+		}()
+	}
+	wg.Wait()
+
+	return violations
+}
+
+// urlOrigin returns the scheme, host and port of the URL, which are all the destination policy depends on.
+func urlOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(decoded), 10, 64)
+}
+
+func NewURLShortener(hostname string, sequence store.ShortenedURLSequence, store store.ShortenedURL, redirects store.ShortenedURL, guardian URLGuardian, policy DestinationPolicy, obfuscator IDObfuscator) URLShortener {
 	return &urlShortener{
-		hostname: hostname,
-		sequence: sequence,
-		store:    store,
-		guardian: guardian,
+		hostname:   hostname,
+		sequence:   sequence,
+		store:      store,
+		redirects:  redirects,
+		guardian:   guardian,
+		policy:     policy,
+		obfuscator: obfuscator,
 	}
 }
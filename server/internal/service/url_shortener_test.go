@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubShortenedURLStore finds the shortened URLs by ID, slug and URL hash and records which of the lookups was used.
+type stubShortenedURLStore struct {
+	store.ShortenedURL
+	shortenedURLs []*model.ShortenedURL
+	slugLookups   int
+	saves         int
+	// onSave runs in place of saving the shortened URL when set.
+	onSave func(shortenedURL *model.ShortenedURL) error
+}
+
+func (s *stubShortenedURLStore) Find(_ context.Context, id int64) (*model.ShortenedURL, error) {
+	for _, shortenedURL := range s.shortenedURLs {
+		if shortenedURL.Id == id {
+			return shortenedURL, nil
+		}
+	}
+
+	return nil, store.ErrShortenedURLNotFound
+}
+
+func (s *stubShortenedURLStore) FindBySlug(_ context.Context, slug string) (*model.ShortenedURL, error) {
+	s.slugLookups++
+	for _, shortenedURL := range s.shortenedURLs {
+		if shortenedURL.Slug == slug {
+			return shortenedURL, nil
+		}
+	}
+
+	return nil, store.ErrShortenedURLNotFound
+}
+
+func (s *stubShortenedURLStore) FindByURLHash(_ context.Context, urlHash string) (*model.ShortenedURL, error) {
+	for _, shortenedURL := range s.shortenedURLs {
+		if shortenedURL.URLHash == urlHash {
+			return shortenedURL, nil
+		}
+	}
+
+	return nil, store.ErrShortenedURLNotFound
+}
+
+func (s *stubShortenedURLStore) Save(_ context.Context, shortenedURL *model.ShortenedURL) error {
+	s.saves++
+	if s.onSave != nil {
+		return s.onSave(shortenedURL)
+	}
+	s.shortenedURLs = append(s.shortenedURLs, shortenedURL)
+
+	return nil
+}
+
+// stubShortenedURLSequence hands out consecutive IDs.
+type stubShortenedURLSequence struct {
+	store.ShortenedURLSequence
+	next int64
+}
+
+func (s *stubShortenedURLSequence) NextId(context.Context) (int64, error) {
+	s.next++
+	return s.next, nil
+}
+
+func TestURLShortenerShortenDeduplicates(t *testing.T) {
+	const rawURL = "https://example.com/docs"
+	urlHash, err := hashURL("owner-1", rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newShortener := func(stub *stubShortenedURLStore) *urlShortener {
+		return &urlShortener{
+			hostname:   "https://snip.example",
+			sequence:   &stubShortenedURLSequence{},
+			store:      stub,
+			guardian:   &stubURLGuardian{},
+			policy:     &stubDestinationPolicy{},
+			obfuscator: NewIDObfuscator([]byte("7f3c2a9e5b1d4c8f")),
+		}
+	}
+	shortenURLReq := model.ShortenURLReq{URL: rawURL, OwnerId: "owner-1", Deduplicate: true}
+
+	t.Run("existing hash", func(t *testing.T) {
+		stub := &stubShortenedURLStore{shortenedURLs: []*model.ShortenedURL{{Id: 7, Slug: "existing", OriginalURL: rawURL, URLHash: urlHash}}}
+
+		shortURL, err := newShortener(stub).Shorten(context.Background(), shortenURLReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shortURL != "https://snip.example/existing" || stub.saves != 0 {
+			t.Errorf("got %q after %d saves, want the existing link without saving", shortURL, stub.saves)
+		}
+	})
+
+	t.Run("concurrent duplicate", func(t *testing.T) {
+		stub := &stubShortenedURLStore{}
+		stub.onSave = func(shortenedURL *model.ShortenedURL) error {
+			if shortenedURL.URLHash != urlHash {
+				t.Errorf("got URL hash %q saved, want %q", shortenedURL.URLHash, urlHash)
+			}
+			// The concurrent request has saved the same URL in the meantime.
+			stub.shortenedURLs = append(stub.shortenedURLs, &model.ShortenedURL{Id: 8, Slug: "winner", OriginalURL: rawURL, URLHash: urlHash})
+			return store.ErrURLHashAlreadyTaken
+		}
+
+		shortURL, err := newShortener(stub).Shorten(context.Background(), shortenURLReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shortURL != "https://snip.example/winner" || stub.saves != 1 {
+			t.Errorf("got %q after %d saves, want the winner's link", shortURL, stub.saves)
+		}
+	})
+}
+
+func TestURLShortenerResolve(t *testing.T) {
+	obfuscator := NewIDObfuscator([]byte("7f3c2a9e5b1d4c8f"))
+	shortener := &urlShortener{obfuscator: obfuscator}
+	generatedSlug, err := shortener.generateSlug(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The custom slug equals the slug generated from ID 7, which belongs to another shortened URL.
+	collidingSlug, err := shortener.generateSlug(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubShortenedURLStore{shortenedURLs: []*model.ShortenedURL{
+		{Id: 42, Slug: generatedSlug, OriginalURL: "https://example.com/generated"},
+		{Id: 7, Slug: "promo", OriginalURL: "https://example.com/promo"},
+		{Id: 8, Slug: collidingSlug, OriginalURL: "https://example.com/colliding"},
+	}}
+	shortener.store, shortener.redirects = stub, stub
+
+	tests := map[string]struct {
+		slug        string
+		want        string
+		slugLookups int
+	}{
+		"generated slug":          {slug: generatedSlug, want: "https://example.com/generated"},
+		"custom slug":             {slug: "promo", want: "https://example.com/promo", slugLookups: 1},
+		"custom generated lookup": {slug: collidingSlug, want: "https://example.com/colliding", slugLookups: 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stub.slugLookups = 0
+
+			got, err := shortener.Resolve(context.Background(), tt.slug)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if stub.slugLookups != tt.slugLookups {
+				t.Errorf("got %d slug lookups, want %d", stub.slugLookups, tt.slugLookups)
+			}
+		})
+	}
+
+	if _, err := shortener.Resolve(context.Background(), "missing"); err != store.ErrShortenedURLNotFound {
+		t.Errorf("got %v, want %v", err, store.ErrShortenedURLNotFound)
+	}
+}
+
+// stubDestinationPolicy refuses the URLs of denied.example and records how many checks have run and at most at once.
+type stubDestinationPolicy struct {
+	mu          sync.Mutex
+	checks      int
+	inFlight    int
+	maxInFlight int
+}
+
+func (p *stubDestinationPolicy) Check(_ context.Context, rawURL string) error {
+	p.mu.Lock()
+	p.checks++
+	p.inFlight++
+	p.maxInFlight = max(p.maxInFlight, p.inFlight)
+	p.mu.Unlock()
+
+	// The check takes about as long as resolving the host would.
+	time.Sleep(10 * time.Millisecond)
+
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	if urlOrigin(rawURL) == "https://denied.example" {
+		return &PolicyViolation{Reason: ReasonDeniedDomain, Message: "denied.example is denied"}
+	}
+
+	return nil
+}
+
+func TestURLShortenerShortenBatchChecksOrigins(t *testing.T) {
+	policy := &stubDestinationPolicy{}
+	shortener := &urlShortener{guardian: &stubURLGuardian{}, policy: policy}
+	var shortenURLReqs []model.ShortenURLReq
+	for i := range 3 * policyCheckConcurrency {
+		// Every origin repeats three times.
+		shortenURLReqs = append(shortenURLReqs, model.ShortenURLReq{URL: fmt.Sprintf("https://host%d.example/page%d", i%policyCheckConcurrency, i)})
+	}
+	for i := range 2 * policyCheckConcurrency {
+		shortenURLReqs = append(shortenURLReqs, model.ShortenURLReq{URL: fmt.Sprintf("https://other%d.example/", i)})
+	}
+	shortenURLReqs = append(shortenURLReqs,
+		model.ShortenURLReq{URL: "https://denied.example/a"},
+		model.ShortenURLReq{URL: "https://denied.example/b"},
+	)
+
+	results, err := shortener.ShortenBatch(context.Background(), shortenURLReqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 3*policyCheckConcurrency + 1; policy.checks != want {
+		t.Errorf("got %d checks, want one per origin %d", policy.checks, want)
+	}
+	if policy.maxInFlight > policyCheckConcurrency {
+		t.Errorf("got %d checks at once, want at most %d", policy.maxInFlight, policyCheckConcurrency)
+	}
+	if policy.maxInFlight < 2 {
+		t.Errorf("got %d checks at once, want them to run concurrently", policy.maxInFlight)
+	}
+	for i, result := range results {
+		// The stub guardian reports every URL as malicious, so only the denied ones fail on the policy.
+		wantErr := ErrMaliciousURLDetected
+		if i >= len(results)-2 {
+			wantErr = ErrDestinationNotAllowed
+		}
+		if !errors.Is(result.Err, wantErr) {
+			t.Errorf("result %d: got %v, want %v", i, result.Err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKey interface {
+	FindByKeyHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	Save(ctx context.Context, apiKey *model.APIKey) error
+}
+
+type apiKeyPG struct {
+	db *pgxpool.Pool
+}
+
+func (s *apiKeyPG) FindByKeyHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	var apiKey model.APIKey
+	sql := "SELECT id, owner_id, name, key_hash, created_at, revoked_at FROM api_keys WHERE key_hash = $1"
+	err := s.db.QueryRow(ctx, sql, keyHash).Scan(&apiKey.Id, &apiKey.OwnerId, &apiKey.Name, &apiKey.KeyHash, &apiKey.CreatedAt, &apiKey.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (s *apiKeyPG) Save(ctx context.Context, apiKey *model.APIKey) error {
+	sql := "INSERT INTO api_keys (owner_id, name, key_hash) VALUES ($1, $2, $3) RETURNING id, created_at"
+	return s.db.QueryRow(ctx, sql, apiKey.OwnerId, apiKey.Name, apiKey.KeyHash).Scan(&apiKey.Id, &apiKey.CreatedAt)
+}
+
+func NewAPIKey(db *pgxpool.Pool) APIKey {
+	return &apiKeyPG{db: db}
+}
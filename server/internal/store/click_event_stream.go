@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/valkey-io/valkey-go"
+	"strconv"
+	"time"
+)
+
+const clickEventsStreamKey = "ClickEvents"
+const clickEventsConsumerGroup = "ClickEventsFlushers"
+
+// clickEventsStreamMaxLen caps the stream so that an unavailable database cannot exhaust Valkey's memory.
+const clickEventsStreamMaxLen = "1000000"
+
+// ClickEventStream buffers click events in Valkey until they are aggregated into the database.
+type ClickEventStream interface {
+	Publish(ctx context.Context, events []model.ClickEvent) error
+	// Consume returns up to count events which are not acknowledged yet along with their stream IDs. The events left
+	// unacknowledged by other consumers for too long are claimed by the given one before new events are read.
+	Consume(ctx context.Context, consumer string, count int64) ([]model.ClickEvent, []string, error)
+	Ack(ctx context.Context, ids []string) error
+}
+
+type clickEventStreamValkey struct {
+	client       valkey.Client
+	claimMinIdle time.Duration
+}
+
+func (s *clickEventStreamValkey) Publish(ctx context.Context, events []model.ClickEvent) error {
+	cmds := make(valkey.Commands, 0, len(events))
+	for _, event := range events {
+		cmds = append(cmds, s.client.B().Xadd().Key(clickEventsStreamKey).
+			Maxlen().Almost().Threshold(clickEventsStreamMaxLen).Id("*").
+			FieldValue().
+			FieldValue("slug", event.Slug).
+			FieldValue("referrer", event.Referrer).
+			FieldValue("userAgent", event.UserAgent).
+			FieldValue("clientIP", event.ClientIP).
+			FieldValue("occurredAt", strconv.FormatInt(event.OccurredAt.UnixMilli(), 10)).
+			Build())
+	}
+
+	for _, res := range s.client.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *clickEventStreamValkey) Consume(ctx context.Context, consumer string, count int64) ([]model.ClickEvent, []string, error) {
+	if err := s.createConsumerGroup(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	// Entries delivered to this consumer but never acknowledged e.g. due to a crash are consumed first.
+	events, ids, err := s.readGroup(ctx, consumer, count, "0")
+	if err != nil || len(ids) > 0 {
+		return events, ids, err
+	}
+
+	// The consumers are named by the hostnames, which change whenever the replica is rescheduled, so the entries of
+	// the replicas which are gone are claimed from them.
+	events, ids, err = s.claim(ctx, consumer, count)
+	if err != nil || len(ids) > 0 {
+		return events, ids, err
+	}
+
+	return s.readGroup(ctx, consumer, count, ">")
+}
+
+func (s *clickEventStreamValkey) claim(ctx context.Context, consumer string, count int64) ([]model.ClickEvent, []string, error) {
+	minIdle := strconv.FormatInt(s.claimMinIdle.Milliseconds(), 10)
+	cmd := s.client.B().Xautoclaim().Key(clickEventsStreamKey).Group(clickEventsConsumerGroup).Consumer(consumer).MinIdleTime(minIdle).Start("0-0").Count(count).Build()
+	reply, err := s.client.Do(ctx, cmd).ToArray()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The reply holds the cursor of the next call, the claimed entries and the IDs of the deleted entries, which have
+	// been dropped from the pending entries.
+	if len(reply) < 2 {
+		return nil, nil, nil
+	}
+	entries, err := reply[1].AsXRange()
+	if err != nil {
+		return nil, nil, err
+	}
+	events, ids := clickEvents(entries)
+
+	return events, ids, nil
+}
+
+func (s *clickEventStreamValkey) readGroup(ctx context.Context, consumer string, count int64, id string) ([]model.ClickEvent, []string, error) {
+	cmd := s.client.B().Xreadgroup().Group(clickEventsConsumerGroup, consumer).Count(count).Streams().Key(clickEventsStreamKey).Id(id).Build()
+	streams, err := s.client.Do(ctx, cmd).AsXRead()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	events, ids := clickEvents(streams[clickEventsStreamKey])
+
+	return events, ids, nil
+}
+
+// clickEvents returns the events of the stream entries along with the IDs of all the entries.
+func clickEvents(entries []valkey.XRangeEntry) ([]model.ClickEvent, []string) {
+	events := make([]model.ClickEvent, 0, len(entries))
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+		// Deleted entries which are still pending are returned without field values.
+		if entry.FieldValues == nil {
+			continue
+		}
+		occurredAt, _ := strconv.ParseInt(entry.FieldValues["occurredAt"], 10, 64)
+		events = append(events, model.ClickEvent{
+			Slug:       entry.FieldValues["slug"],
+			Referrer:   entry.FieldValues["referrer"],
+			UserAgent:  entry.FieldValues["userAgent"],
+			ClientIP:   entry.FieldValues["clientIP"],
+			OccurredAt: time.UnixMilli(occurredAt).UTC(),
+		})
+	}
+
+	return events, ids
+}
+
+func (s *clickEventStreamValkey) Ack(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cmds := valkey.Commands{
+		s.client.B().Xack().Key(clickEventsStreamKey).Group(clickEventsConsumerGroup).Id(ids...).Build(),
+		s.client.B().Xdel().Key(clickEventsStreamKey).Id(ids...).Build(),
+	}
+	for _, res := range s.client.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *clickEventStreamValkey) createConsumerGroup(ctx context.Context) error {
+	cmd := s.client.B().XgroupCreate().Key(clickEventsStreamKey).Group(clickEventsConsumerGroup).Id("0").Mkstream().Build()
+	err := s.client.Do(ctx, cmd).Error()
+	if valkeyErr, ok := valkey.IsValkeyErr(err); ok && valkeyErr.IsBusyGroup() {
+		return nil
+	}
+
+	return err
+}
+
+// NewClickEventStream creates stream whose consumers claim the entries left unacknowledged by other consumers for
+// claimMinIdle, which has to be well above the time it takes to flush the events.
+func NewClickEventStream(client valkey.Client, claimMinIdle time.Duration) ClickEventStream {
+	return &clickEventStreamValkey{client: client, claimMinIdle: claimMinIdle}
+}
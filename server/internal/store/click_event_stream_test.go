@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClickEventStream(t *testing.T) {
+	server, client := newTestValkey(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+	stream := NewClickEventStream(client, time.Minute)
+	ctx := context.Background()
+	published := []model.ClickEvent{
+		{Slug: "abc", Referrer: "https://news.example/", UserAgent: "curl/8.0", ClientIP: "203.0.113.7", OccurredAt: now},
+		{Slug: "def", OccurredAt: now.Add(time.Second)},
+	}
+	if err := stream.Publish(ctx, published); err != nil {
+		t.Fatal(err)
+	}
+
+	events, ids, err := stream.Consume(ctx, "gone", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(events, published) || len(ids) != 2 {
+		t.Fatalf("got %+v with %d IDs, want the published events", events, len(ids))
+	}
+
+	t.Run("pending entries are claimed once idle", func(t *testing.T) {
+		// The consumer "gone" never acknowledges its entries.
+		if _, ids, err = stream.Consume(ctx, "alive", 10); err != nil || len(ids) != 0 {
+			t.Fatalf("got %d entries, %v, want the entries of recent consumer kept", len(ids), err)
+		}
+
+		server.SetTime(now.Add(2 * time.Minute))
+		events, ids, err = stream.Consume(ctx, "alive", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(events, published) {
+			t.Errorf("got %+v, want the idle entries claimed", events)
+		}
+	})
+
+	t.Run("acknowledged entries are gone", func(t *testing.T) {
+		if err = stream.Ack(ctx, ids); err != nil {
+			t.Fatal(err)
+		}
+		server.SetTime(now.Add(time.Hour))
+		for _, consumer := range []string{"gone", "alive"} {
+			if _, ids, err := stream.Consume(ctx, consumer, 10); err != nil || len(ids) != 0 {
+				t.Errorf("%s got %d entries, %v, want none", consumer, len(ids), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrGuardianOverrideNotFound = errors.New("guardian override not found")
+var ErrGuardianOverrideExists = errors.New("guardian override already exists")
+
+const guardianOverrideUniqueIndex = "guardian_overrides_kind_granularity_pattern_uindex"
+
+const guardianOverrideColumns = "id, kind, granularity, pattern, url, reason, author, created_at, expires_at"
+
+// The actions recorded in the audit trail of the guardian overrides.
+const (
+	auditActionCreate = "create"
+	auditActionDelete = "delete"
+)
+
+// GuardianOverride stores the manual overrides of the guardian. Every change is recorded in the audit trail within
+// the same transaction.
+type GuardianOverride interface {
+	// ListActive returns the overrides which haven't expired yet.
+	ListActive(ctx context.Context) ([]*model.GuardianOverride, error)
+	Save(ctx context.Context, override *model.GuardianOverride) error
+	// Delete deletes the override of the given ID on behalf of the author and returns it.
+	Delete(ctx context.Context, id int64, author string) (*model.GuardianOverride, error)
+	// ListAudit returns up to limit latest changes of the overrides ordered from the newest to the oldest.
+	ListAudit(ctx context.Context, limit int) ([]*model.GuardianOverrideAudit, error)
+}
+
+type guardianOverridePG struct {
+	db *pgxpool.Pool
+}
+
+func (s *guardianOverridePG) ListActive(ctx context.Context) ([]*model.GuardianOverride, error) {
+	sql := "SELECT " + guardianOverrideColumns + " FROM guardian_overrides WHERE expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP ORDER BY id"
+	rows, err := s.db.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.GuardianOverride, error) {
+		return scanGuardianOverride(row)
+	})
+}
+
+func (s *guardianOverridePG) Save(ctx context.Context, override *model.GuardianOverride) error {
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// Expired override of the same pattern no longer has any effect, it's replaced rather than conflicting.
+		sql := "DELETE FROM guardian_overrides WHERE kind = $1 AND granularity = $2 AND pattern = $3 AND expires_at <= CURRENT_TIMESTAMP"
+		if _, err := tx.Exec(ctx, sql, override.Kind, override.Granularity, override.Pattern); err != nil {
+			return err
+		}
+
+		sql = "INSERT INTO guardian_overrides (kind, granularity, pattern, url, reason, author, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at"
+		err := tx.QueryRow(ctx, sql, override.Kind, override.Granularity, override.Pattern, override.URL, override.Reason, override.Author, override.ExpiresAt).
+			Scan(&override.Id, &override.CreatedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == guardianOverrideUniqueIndex {
+				return ErrGuardianOverrideExists
+			}
+			return err
+		}
+
+		return audit(ctx, tx, auditActionCreate, override.Author, override)
+	})
+}
+
+func (s *guardianOverridePG) Delete(ctx context.Context, id int64, author string) (*model.GuardianOverride, error) {
+	var override *model.GuardianOverride
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		sql := "DELETE FROM guardian_overrides WHERE id = $1 RETURNING " + guardianOverrideColumns
+		var err error
+		override, err = scanGuardianOverride(tx.QueryRow(ctx, sql, id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrGuardianOverrideNotFound
+			}
+			return err
+		}
+
+		return audit(ctx, tx, auditActionDelete, author, override)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return override, nil
+}
+
+func (s *guardianOverridePG) ListAudit(ctx context.Context, limit int) ([]*model.GuardianOverrideAudit, error) {
+	sql := "SELECT id, override_id, action, author, override, created_at FROM guardian_override_audit ORDER BY id DESC LIMIT $1"
+	rows, err := s.db.Query(ctx, sql, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.GuardianOverrideAudit, error) {
+		var entry model.GuardianOverrideAudit
+		err := row.Scan(&entry.Id, &entry.OverrideId, &entry.Action, &entry.Author, &entry.Override, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &entry, nil
+	})
+}
+
+// audit records the change of the override along with its snapshot, so that the trail outlives the override.
+func audit(ctx context.Context, tx pgx.Tx, action string, author string, override *model.GuardianOverride) error {
+	sql := "INSERT INTO guardian_override_audit (override_id, action, author, override) VALUES ($1, $2, $3, $4)"
+	_, err := tx.Exec(ctx, sql, override.Id, action, author, override)
+
+	return err
+}
+
+func scanGuardianOverride(row pgx.Row) (*model.GuardianOverride, error) {
+	var override model.GuardianOverride
+	err := row.Scan(
+		&override.Id,
+		&override.Kind,
+		&override.Granularity,
+		&override.Pattern,
+		&override.URL,
+		&override.Reason,
+		&override.Author,
+		&override.CreatedAt,
+		&override.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &override, nil
+}
+
+func NewGuardianOverride(db *pgxpool.Pool) GuardianOverride {
+	return &guardianOverridePG{db: db}
+}
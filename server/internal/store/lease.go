@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/valkey-io/valkey-go"
+	"strconv"
+	"time"
+)
+
+var ErrLeaseHeld = errors.New("lease held by another holder")
+var ErrLeaseLost = errors.New("lease lost")
+
+// Lease grants exclusive right to do some work to single holder at a time. Every acquisition is given a fencing
+// token greater than the tokens of all the previous acquisitions, so that the writes of the holders which lost the
+// lease without noticing can be refused.
+type Lease interface {
+	// Acquire takes the lease unless it's held by another holder, in which case ErrLeaseHeld is returned.
+	Acquire(ctx context.Context) (int64, error)
+	// Renew extends the lease acquired with the given token or returns ErrLeaseLost when it's no longer held.
+	Renew(ctx context.Context, token int64) error
+	// Release gives up the lease acquired with the given token, so that another holder doesn't need to wait for it
+	// to expire.
+	Release(ctx context.Context, token int64) error
+}
+
+// acquireLeaseScript sets the holder with SET NX PX and increments the fencing token. Holder acquiring the lease it
+// already holds keeps its token.
+var acquireLeaseScript = valkey.NewLuaScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return tonumber(redis.call('GET', KEYS[2]))
+end
+return 0
+`)
+
+// renewLeaseScript extends the lease when it's still held by the holder with the given token.
+var renewLeaseScript = valkey.NewLuaScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] and redis.call('GET', KEYS[2]) == ARGV[3] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript deletes the lease when it's still held by the holder with the given token.
+var releaseLeaseScript = valkey.NewLuaScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] and redis.call('GET', KEYS[2]) == ARGV[2] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type leaseValkey struct {
+	client valkey.Client
+	holder string
+	ttl    time.Duration
+	// The keys share hash tag, so that the scripts are valid in cluster as well.
+	key      string
+	tokenKey string
+}
+
+func (l *leaseValkey) Acquire(ctx context.Context) (int64, error) {
+	ttl := strconv.FormatInt(l.ttl.Milliseconds(), 10)
+	token, err := acquireLeaseScript.Exec(ctx, l.client, []string{l.key, l.tokenKey}, []string{l.holder, ttl}).AsInt64()
+	if err != nil {
+		return 0, err
+	}
+	if token == 0 {
+		return 0, ErrLeaseHeld
+	}
+
+	return token, nil
+}
+
+func (l *leaseValkey) Renew(ctx context.Context, token int64) error {
+	ttl := strconv.FormatInt(l.ttl.Milliseconds(), 10)
+	renewed, err := renewLeaseScript.Exec(ctx, l.client, []string{l.key, l.tokenKey}, []string{l.holder, ttl, strconv.FormatInt(token, 10)}).AsInt64()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (l *leaseValkey) Release(ctx context.Context, token int64) error {
+	return releaseLeaseScript.Exec(ctx, l.client, []string{l.key, l.tokenKey}, []string{l.holder, strconv.FormatInt(token, 10)}).Error()
+}
+
+// NewLease creates the lease of the given name held by the given holder, which has to be unique among the replicas.
+// The lease expires unless renewed within ttl.
+func NewLease(client valkey.Client, name string, holder string, ttl time.Duration) Lease {
+	return &leaseValkey{
+		client:   client,
+		holder:   holder,
+		ttl:      ttl,
+		key:      fmt.Sprintf("Lease:{%s}", name),
+		tokenKey: fmt.Sprintf("LeaseFencingToken:{%s}", name),
+	}
+}
@@ -3,16 +3,51 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/aboyadzhiev/snip/server/internal/model"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"time"
 )
 
 var ErrShortenedURLNotFound = errors.New("shortened url not found")
+var ErrSlugAlreadyTaken = errors.New("slug already taken")
+var ErrURLHashAlreadyTaken = errors.New("url hash already taken")
+var ErrIdAlreadyTaken = errors.New("id already taken")
+
+const uniqueViolationCode = "23505"
+const primaryKey = "url_map_pk"
+const slugUniqueIndex = "url_map_slug_uindex"
+const urlHashUniqueIndex = "url_map_url_hash_uindex"
+
+const shortenedURLColumns = "id, slug, original_url, created_at, expires_at, COALESCE(url_hash, ''), COALESCE(owner_id, ''), deleted_at, quarantined_at"
 
 type ShortenedURL interface {
 	Find(ctx context.Context, id int64) (*model.ShortenedURL, error)
+	FindBySlug(ctx context.Context, slug string) (*model.ShortenedURL, error)
+	FindByURLHash(ctx context.Context, urlHash string) (*model.ShortenedURL, error)
+	// List returns the shortened URLs matching the filter ordered from the newest to the oldest. Deleted shortened
+	// URLs are never listed.
+	List(ctx context.Context, filter model.ShortenedURLFilter) ([]*model.ShortenedURL, error)
+	// Scan returns up to limit not deleted and not quarantined shortened URLs with IDs greater than afterId ordered by
+	// ID, so that all of them can be walked through in batches.
+	Scan(ctx context.Context, afterId int64, limit int) ([]*model.ShortenedURL, error)
 	Save(ctx context.Context, shortenedURL *model.ShortenedURL) error
+	// SaveAll inserts the given shortened URLs at once. Either all of them are saved or none.
+	SaveAll(ctx context.Context, shortenedURLs []*model.ShortenedURL) error
+	// Update overwrites the original URL, the expiration time, the URL hash and the quarantine of not deleted
+	// shortened URL.
+	Update(ctx context.Context, shortenedURL *model.ShortenedURL) error
+	// Delete marks the shortened URL as deleted, the row itself is kept so that its slug is never reused.
+	Delete(ctx context.Context, shortenedURL *model.ShortenedURL) error
+	// Quarantine marks the given shortened URLs as quarantined and excludes them from deduplication.
+	Quarantine(ctx context.Context, shortenedURLs []*model.ShortenedURL) error
+	// ArchiveExpired moves the shortened URLs which expired before the given moment into the archive.
+	ArchiveExpired(ctx context.Context, expiredBefore time.Time) (int64, error)
+	// MaxId returns the greatest ID ever used, including the archived shortened URLs.
+	MaxId(ctx context.Context) (int64, error)
 }
 
 type shortenedURLPG struct {
@@ -20,9 +55,22 @@ type shortenedURLPG struct {
 }
 
 func (s *shortenedURLPG) Find(ctx context.Context, id int64) (*model.ShortenedURL, error) {
-	var shortenedURL model.ShortenedURL
-	sql := "SELECT id, slug, original_url, created_at FROM url_map WHERE id = $1"
-	err := s.db.QueryRow(ctx, sql, id).Scan(&shortenedURL.Id, &shortenedURL.Slug, &shortenedURL.OriginalURL, &shortenedURL.CreatedAt)
+	sql := "SELECT " + shortenedURLColumns + " FROM url_map WHERE id = $1"
+	return s.findOne(ctx, sql, id)
+}
+
+func (s *shortenedURLPG) FindBySlug(ctx context.Context, slug string) (*model.ShortenedURL, error) {
+	sql := "SELECT " + shortenedURLColumns + " FROM url_map WHERE slug = $1"
+	return s.findOne(ctx, sql, slug)
+}
+
+func (s *shortenedURLPG) FindByURLHash(ctx context.Context, urlHash string) (*model.ShortenedURL, error) {
+	sql := "SELECT " + shortenedURLColumns + " FROM url_map WHERE url_hash = $1"
+	return s.findOne(ctx, sql, urlHash)
+}
+
+func (s *shortenedURLPG) findOne(ctx context.Context, sql string, args ...any) (*model.ShortenedURL, error) {
+	shortenedURL, err := scanShortenedURL(s.db.QueryRow(ctx, sql, args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrShortenedURLNotFound
@@ -30,19 +78,210 @@ func (s *shortenedURLPG) Find(ctx context.Context, id int64) (*model.ShortenedUR
 		return nil, err
 	}
 
-	return &shortenedURL, nil
+	return shortenedURL, nil
+}
+
+func (s *shortenedURLPG) List(ctx context.Context, filter model.ShortenedURLFilter) ([]*model.ShortenedURL, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []any
+	condition := func(format string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.OwnerId != "" {
+		condition("owner_id = $%d", filter.OwnerId)
+	}
+	if filter.BeforeId > 0 {
+		condition("id < $%d", filter.BeforeId)
+	}
+	if filter.URLContains != "" {
+		condition("original_url ILIKE '%%' || $%d || '%%'", escapeLike(filter.URLContains))
+	}
+	if filter.CreatedAfter != nil {
+		condition("created_at >= $%d", filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		condition("created_at < $%d", filter.CreatedBefore.UTC())
+	}
+	args = append(args, filter.Limit)
+
+	sql := fmt.Sprintf("SELECT %s FROM url_map WHERE %s ORDER BY id DESC LIMIT $%d",
+		shortenedURLColumns, strings.Join(conditions, " AND "), len(args))
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.ShortenedURL, error) {
+		return scanShortenedURL(row)
+	})
+}
+
+func (s *shortenedURLPG) Scan(ctx context.Context, afterId int64, limit int) ([]*model.ShortenedURL, error) {
+	sql := "SELECT " + shortenedURLColumns + " FROM url_map WHERE id > $1 AND deleted_at IS NULL AND quarantined_at IS NULL ORDER BY id LIMIT $2"
+	rows, err := s.db.Query(ctx, sql, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.ShortenedURL, error) {
+		return scanShortenedURL(row)
+	})
 }
 
 func (s *shortenedURLPG) Save(ctx context.Context, shortenedURL *model.ShortenedURL) error {
-	sql := "INSERT INTO url_map (id, slug, original_url) VALUES ($1, $2, $3)"
-	_, err := s.db.Exec(ctx, sql, shortenedURL.Id, shortenedURL.Slug, shortenedURL.OriginalURL)
+	sql := "INSERT INTO url_map (id, slug, original_url, expires_at, url_hash, owner_id) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))"
+	_, err := s.db.Exec(ctx, sql, shortenedURL.Id, shortenedURL.Slug, shortenedURL.OriginalURL, shortenedURL.ExpiresAt, shortenedURL.URLHash, shortenedURL.OwnerId)
+	if err != nil {
+		return uniqueViolationErr(err)
+	}
+
+	return nil
+}
+
+func (s *shortenedURLPG) SaveAll(ctx context.Context, shortenedURLs []*model.ShortenedURL) error {
+	columns := []string{"id", "slug", "original_url", "expires_at", "url_hash", "owner_id"}
+	rows := pgx.CopyFromSlice(len(shortenedURLs), func(i int) ([]any, error) {
+		shortenedURL := shortenedURLs[i]
+		return []any{
+			shortenedURL.Id,
+			shortenedURL.Slug,
+			shortenedURL.OriginalURL,
+			shortenedURL.ExpiresAt,
+			nullIfEmpty(shortenedURL.URLHash),
+			nullIfEmpty(shortenedURL.OwnerId),
+		}, nil
+	})
+	_, err := s.db.CopyFrom(ctx, pgx.Identifier{"url_map"}, columns, rows)
+	if err != nil {
+		return uniqueViolationErr(err)
+	}
+
+	return nil
+}
+
+func (s *shortenedURLPG) Update(ctx context.Context, shortenedURL *model.ShortenedURL) error {
+	// The quarantine is lifted only when the original URL changes and the URL hash is only ever released, so that
+	// the update never undoes the quarantine of the rescanner which has run concurrently.
+	sql := "UPDATE url_map SET original_url = $2, expires_at = $3, " +
+		"url_hash = CASE WHEN $4::text = '' THEN NULL ELSE url_hash END, " +
+		"quarantined_at = CASE WHEN original_url <> $2 THEN NULL ELSE quarantined_at END " +
+		"WHERE id = $1 AND deleted_at IS NULL RETURNING COALESCE(url_hash, ''), quarantined_at"
+	err := s.db.QueryRow(ctx, sql, shortenedURL.Id, shortenedURL.OriginalURL, shortenedURL.ExpiresAt, shortenedURL.URLHash).
+		Scan(&shortenedURL.URLHash, &shortenedURL.QuarantinedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrShortenedURLNotFound
+	}
+
+	return err
+}
+
+func (s *shortenedURLPG) Delete(ctx context.Context, shortenedURL *model.ShortenedURL) error {
+	// The URL hash is released so that deduplication never hands out deleted shortened URL.
+	sql := "UPDATE url_map SET deleted_at = CURRENT_TIMESTAMP, url_hash = NULL WHERE id = $1 AND deleted_at IS NULL"
+	tag, err := s.db.Exec(ctx, sql, shortenedURL.Id)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrShortenedURLNotFound
+	}
 
 	return nil
 }
 
+func (s *shortenedURLPG) Quarantine(ctx context.Context, shortenedURLs []*model.ShortenedURL) error {
+	ids := make([]int64, 0, len(shortenedURLs))
+	for _, shortenedURL := range shortenedURLs {
+		ids = append(ids, shortenedURL.Id)
+	}
+	// The URL hash is released so that deduplication never hands out quarantined shortened URL.
+	sql := "UPDATE url_map SET quarantined_at = CURRENT_TIMESTAMP, url_hash = NULL WHERE id = ANY($1) AND quarantined_at IS NULL"
+	_, err := s.db.Exec(ctx, sql, ids)
+
+	return err
+}
+
+// ArchiveExpired overwrites the archived row of the same ID, so that the archive always holds what was deleted.
+func (s *shortenedURLPG) ArchiveExpired(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	sql := `WITH expired AS (
+		DELETE FROM url_map WHERE expires_at < $1 AND deleted_at IS NULL RETURNING id, slug, original_url, created_at, expires_at
+	)
+	INSERT INTO url_map_archive (id, slug, original_url, created_at, expires_at)
+	SELECT id, slug, original_url, created_at, expires_at FROM expired
+	ON CONFLICT (id) DO UPDATE SET
+		slug = EXCLUDED.slug,
+		original_url = EXCLUDED.original_url,
+		created_at = EXCLUDED.created_at,
+		expires_at = EXCLUDED.expires_at,
+		archived_at = CURRENT_TIMESTAMP`
+	tag, err := s.db.Exec(ctx, sql, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (s *shortenedURLPG) MaxId(ctx context.Context) (int64, error) {
+	var maxId int64
+	sql := "SELECT COALESCE(GREATEST((SELECT MAX(id) FROM url_map), (SELECT MAX(id) FROM url_map_archive)), 0)"
+	err := s.db.QueryRow(ctx, sql).Scan(&maxId)
+
+	return maxId, err
+}
+
+func scanShortenedURL(row pgx.Row) (*model.ShortenedURL, error) {
+	var shortenedURL model.ShortenedURL
+	err := row.Scan(
+		&shortenedURL.Id,
+		&shortenedURL.Slug,
+		&shortenedURL.OriginalURL,
+		&shortenedURL.CreatedAt,
+		&shortenedURL.ExpiresAt,
+		&shortenedURL.URLHash,
+		&shortenedURL.OwnerId,
+		&shortenedURL.DeletedAt,
+		&shortenedURL.QuarantinedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shortenedURL, nil
+}
+
+// uniqueViolationErr translates violations of the unique indexes to the corresponding errors.
+func uniqueViolationErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		switch pgErr.ConstraintName {
+		case primaryKey:
+			return ErrIdAlreadyTaken
+		case slugUniqueIndex:
+			return ErrSlugAlreadyTaken
+		case urlHashUniqueIndex:
+			return ErrURLHashAlreadyTaken
+		}
+	}
+
+	return err
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// escapeLike escapes the wildcards of LIKE patterns.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 func NewShortenedURL(db *pgxpool.Pool) ShortenedURL {
 	return &shortenedURLPG{db: db}
 }
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/valkey-io/valkey-go"
+	"time"
+)
+
+// shortenedURLMissMarker is cached in place of shortened URLs which don't exist.
+const shortenedURLMissMarker = "-"
+
+// shortenedURLEvictedMarker replaces the evicted entries for shortenedURLEvictionTTL. The lookups cache their results
+// only when the key is absent, so that a lookup which read the store before the change can't cache its stale result.
+const shortenedURLEvictedMarker = "!"
+const shortenedURLEvictionTTL = 5 * time.Second
+
+// shortenedURLCache is a read-through cache in front of another ShortenedURL store. Mappings are kept in Valkey
+// and additionally in the client-side cache of valkey-go, which Valkey invalidates whenever the keys change.
+type shortenedURLCache struct {
+	store ShortenedURL
+	// uncached serves the lookups while Valkey is unavailable, its rows are never cached.
+	uncached    ShortenedURL
+	client      valkey.Client
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+func (s *shortenedURLCache) Find(ctx context.Context, id int64) (*model.ShortenedURL, error) {
+	return s.readThrough(ctx, shortenedURLIdKey(id), func(store ShortenedURL) (*model.ShortenedURL, error) {
+		return store.Find(ctx, id)
+	})
+}
+
+func (s *shortenedURLCache) FindBySlug(ctx context.Context, slug string) (*model.ShortenedURL, error) {
+	return s.readThrough(ctx, shortenedURLSlugKey(slug), func(store ShortenedURL) (*model.ShortenedURL, error) {
+		return store.FindBySlug(ctx, slug)
+	})
+}
+
+func (s *shortenedURLCache) FindByURLHash(ctx context.Context, urlHash string) (*model.ShortenedURL, error) {
+	return s.store.FindByURLHash(ctx, urlHash)
+}
+
+func (s *shortenedURLCache) Save(ctx context.Context, shortenedURL *model.ShortenedURL) error {
+	if err := s.store.Save(ctx, shortenedURL); err != nil {
+		return err
+	}
+	// Drop the cached misses of the new shortened URL.
+	s.evict(ctx, shortenedURL)
+
+	return nil
+}
+
+func (s *shortenedURLCache) SaveAll(ctx context.Context, shortenedURLs []*model.ShortenedURL) error {
+	if err := s.store.SaveAll(ctx, shortenedURLs); err != nil {
+		return err
+	}
+	s.evict(ctx, shortenedURLs...)
+
+	return nil
+}
+
+func (s *shortenedURLCache) List(ctx context.Context, filter model.ShortenedURLFilter) ([]*model.ShortenedURL, error) {
+	return s.store.List(ctx, filter)
+}
+
+func (s *shortenedURLCache) Scan(ctx context.Context, afterId int64, limit int) ([]*model.ShortenedURL, error) {
+	return s.store.Scan(ctx, afterId, limit)
+}
+
+func (s *shortenedURLCache) Update(ctx context.Context, shortenedURL *model.ShortenedURL) error {
+	if err := s.store.Update(ctx, shortenedURL); err != nil {
+		return err
+	}
+	s.evict(ctx, shortenedURL)
+
+	return nil
+}
+
+func (s *shortenedURLCache) Delete(ctx context.Context, shortenedURL *model.ShortenedURL) error {
+	if err := s.store.Delete(ctx, shortenedURL); err != nil {
+		return err
+	}
+	s.evict(ctx, shortenedURL)
+
+	return nil
+}
+
+func (s *shortenedURLCache) Quarantine(ctx context.Context, shortenedURLs []*model.ShortenedURL) error {
+	if err := s.store.Quarantine(ctx, shortenedURLs); err != nil {
+		return err
+	}
+	s.evict(ctx, shortenedURLs...)
+
+	return nil
+}
+
+func (s *shortenedURLCache) ArchiveExpired(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	return s.store.ArchiveExpired(ctx, expiredBefore)
+}
+
+func (s *shortenedURLCache) MaxId(ctx context.Context) (int64, error) {
+	return s.store.MaxId(ctx)
+}
+
+// readThrough loads the misses from the store, so that the cache only ever holds rows read from the primary.
+func (s *shortenedURLCache) readThrough(ctx context.Context, key string, find func(ShortenedURL) (*model.ShortenedURL, error)) (*model.ShortenedURL, error) {
+	cached, err := s.client.DoCache(ctx, s.client.B().Get().Key(key).Cache(), s.ttl).ToString()
+	if err == nil && cached != shortenedURLEvictedMarker {
+		if cached == shortenedURLMissMarker {
+			return nil, ErrShortenedURLNotFound
+		}
+		var shortenedURL model.ShortenedURL
+		if err = json.Unmarshal([]byte(cached), &shortenedURL); err == nil {
+			return &shortenedURL, nil
+		}
+	}
+	if err != nil && !valkey.IsValkeyNil(err) {
+		// Valkey being down doesn't break redirects, there is nothing to cache the result into anyway.
+		return find(s.uncached)
+	}
+
+	shortenedURL, err := find(s.store)
+	if err != nil {
+		if errors.Is(err, ErrShortenedURLNotFound) {
+			s.set(ctx, key, shortenedURLMissMarker, s.negativeTTL)
+		}
+		return nil, err
+	}
+
+	ttl := s.ttl
+	if shortenedURL.ExpiresAt != nil {
+		// Expired shortened URLs remain cached so that they keep resolving as gone until archived.
+		ttl = min(ttl, max(time.Until(*shortenedURL.ExpiresAt), s.negativeTTL))
+	}
+	if value, err := json.Marshal(shortenedURL); err == nil {
+		s.set(ctx, key, string(value), ttl)
+	}
+
+	return shortenedURL, nil
+}
+
+// set caches the value unless the key is present, in particular unless it has been evicted meanwhile.
+func (s *shortenedURLCache) set(ctx context.Context, key string, value string, ttl time.Duration) {
+	s.client.Do(ctx, s.client.B().Set().Key(key).Value(value).Nx().Px(ttl).Build())
+}
+
+func (s *shortenedURLCache) evict(ctx context.Context, shortenedURLs ...*model.ShortenedURL) {
+	// The keys are replaced separately as they may belong to different cluster slots.
+	cmds := make(valkey.Commands, 0, 2*len(shortenedURLs))
+	for _, shortenedURL := range shortenedURLs {
+		for _, key := range []string{shortenedURLIdKey(shortenedURL.Id), shortenedURLSlugKey(shortenedURL.Slug)} {
+			cmds = append(cmds, s.client.B().Set().Key(key).Value(shortenedURLEvictedMarker).Px(shortenedURLEvictionTTL).Build())
+		}
+	}
+	s.client.DoMulti(ctx, cmds...)
+}
+
+func shortenedURLIdKey(id int64) string {
+	return fmt.Sprintf("ShortenedURL:id:%d", id)
+}
+
+func shortenedURLSlugKey(slug string) string {
+	return fmt.Sprintf("ShortenedURL:slug:%s", slug)
+}
+
+// NewCachedShortenedURL decorates the given store with read-through cache. Found shortened URLs are cached for ttl
+// and the misses for negativeTTL.
+func NewCachedShortenedURL(store ShortenedURL, client valkey.Client, ttl time.Duration, negativeTTL time.Duration) ShortenedURL {
+	return NewCachedShortenedURLWithFallback(store, store, client, ttl, negativeTTL)
+}
+
+// NewCachedShortenedURLWithFallback is NewCachedShortenedURL which serves the lookups from uncached instead of the
+// store while Valkey is unavailable. The rows read from uncached are never cached, so it may read from replicas.
+func NewCachedShortenedURLWithFallback(store ShortenedURL, uncached ShortenedURL, client valkey.Client, ttl time.Duration, negativeTTL time.Duration) ShortenedURL {
+	return &shortenedURLCache{
+		store:       store,
+		uncached:    uncached,
+		client:      client,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
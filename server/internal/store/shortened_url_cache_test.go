@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"testing"
+	"time"
+)
+
+// stubShortenedURLStore keeps the shortened URLs by slug and counts the lookups reaching it.
+type stubShortenedURLStore struct {
+	ShortenedURL
+	bySlug  map[string]*model.ShortenedURL
+	lookups int
+	// onLookup runs after the store has been read and before the result is returned.
+	onLookup func()
+}
+
+func (s *stubShortenedURLStore) FindBySlug(_ context.Context, slug string) (*model.ShortenedURL, error) {
+	s.lookups++
+	shortenedURL, ok := s.bySlug[slug]
+	if s.onLookup != nil {
+		s.onLookup()
+	}
+	if !ok {
+		return nil, ErrShortenedURLNotFound
+	}
+
+	return shortenedURL, nil
+}
+
+func (s *stubShortenedURLStore) Save(_ context.Context, shortenedURL *model.ShortenedURL) error {
+	s.bySlug[shortenedURL.Slug] = shortenedURL
+	return nil
+}
+
+func (s *stubShortenedURLStore) Update(_ context.Context, shortenedURL *model.ShortenedURL) error {
+	s.bySlug[shortenedURL.Slug] = shortenedURL
+	return nil
+}
+
+func (s *stubShortenedURLStore) Delete(_ context.Context, shortenedURL *model.ShortenedURL) error {
+	delete(s.bySlug, shortenedURL.Slug)
+	return nil
+}
+
+func TestCachedShortenedURLMisses(t *testing.T) {
+	server, client := newTestValkey(t)
+	stub := &stubShortenedURLStore{bySlug: map[string]*model.ShortenedURL{}}
+	cache := NewCachedShortenedURL(stub, client, time.Hour, time.Minute)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := cache.FindBySlug(ctx, "abc"); err != ErrShortenedURLNotFound {
+			t.Fatalf("got %v, want %v", err, ErrShortenedURLNotFound)
+		}
+	}
+	if stub.lookups != 1 {
+		t.Errorf("got %d lookups, want the miss cached", stub.lookups)
+	}
+	if ttl := server.TTL(shortenedURLSlugKey("abc")); ttl != time.Minute {
+		t.Errorf("got miss TTL %v, want %v", ttl, time.Minute)
+	}
+
+	server.FastForward(time.Minute)
+	if _, err := cache.FindBySlug(ctx, "abc"); err != ErrShortenedURLNotFound {
+		t.Fatalf("got %v, want %v", err, ErrShortenedURLNotFound)
+	}
+	if stub.lookups != 2 {
+		t.Errorf("got %d lookups, want the expired miss looked up again", stub.lookups)
+	}
+}
+
+func TestCachedShortenedURLExpiryCapsTTL(t *testing.T) {
+	server, client := newTestValkey(t)
+	expiresAt := time.Now().Add(10 * time.Minute)
+	stub := &stubShortenedURLStore{bySlug: map[string]*model.ShortenedURL{
+		"abc": {Id: 1, Slug: "abc", OriginalURL: "https://example.com/", ExpiresAt: &expiresAt},
+	}}
+	cache := NewCachedShortenedURL(stub, client, time.Hour, time.Minute)
+
+	if _, err := cache.FindBySlug(context.Background(), "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := server.TTL(shortenedURLSlugKey("abc")); ttl > 10*time.Minute || ttl < 9*time.Minute {
+		t.Errorf("got TTL %v, want it capped by the expiry in 10m", ttl)
+	}
+}
+
+func TestCachedShortenedURLEviction(t *testing.T) {
+	tests := map[string]func(cache ShortenedURL, shortenedURL *model.ShortenedURL) error{
+		"save": func(cache ShortenedURL, shortenedURL *model.ShortenedURL) error {
+			return cache.Save(context.Background(), shortenedURL)
+		},
+		"update": func(cache ShortenedURL, shortenedURL *model.ShortenedURL) error {
+			return cache.Update(context.Background(), shortenedURL)
+		},
+		"delete": func(cache ShortenedURL, shortenedURL *model.ShortenedURL) error {
+			return cache.Delete(context.Background(), shortenedURL)
+		},
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, client := newTestValkey(t)
+			previous := &model.ShortenedURL{Id: 1, Slug: "abc", OriginalURL: "https://example.com/old"}
+			stub := &stubShortenedURLStore{bySlug: map[string]*model.ShortenedURL{}}
+			if name != "save" {
+				stub.bySlug["abc"] = previous
+			}
+			cache := NewCachedShortenedURL(stub, client, time.Hour, time.Minute)
+			ctx := context.Background()
+			_, _ = cache.FindBySlug(ctx, "abc")
+
+			if err := change(cache, &model.ShortenedURL{Id: 1, Slug: "abc", OriginalURL: "https://example.com/new"}); err != nil {
+				t.Fatal(err)
+			}
+
+			shortenedURL, err := cache.FindBySlug(ctx, "abc")
+			if name == "delete" {
+				if err != ErrShortenedURLNotFound {
+					t.Errorf("got %v, want %v", err, ErrShortenedURLNotFound)
+				}
+				return
+			}
+			if err != nil || shortenedURL.OriginalURL != "https://example.com/new" {
+				t.Errorf("got %v, %v, want the changed shortened URL", shortenedURL, err)
+			}
+		})
+	}
+}
+
+func TestCachedShortenedURLLateMissDoesNotOverwriteSave(t *testing.T) {
+	_, client := newTestValkey(t)
+	stub := &stubShortenedURLStore{bySlug: map[string]*model.ShortenedURL{}}
+	cache := NewCachedShortenedURL(stub, client, time.Hour, time.Minute)
+	ctx := context.Background()
+	saved := &model.ShortenedURL{Id: 1, Slug: "abc", OriginalURL: "https://example.com/"}
+	// The shortened URL is saved after the lookup missed it in the store, but before the miss is cached.
+	stub.onLookup = func() {
+		stub.onLookup = nil
+		if err := cache.Save(ctx, saved); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := cache.FindBySlug(ctx, "abc"); err != ErrShortenedURLNotFound {
+		t.Fatalf("got %v, want the late miss", err)
+	}
+
+	shortenedURL, err := cache.FindBySlug(ctx, "abc")
+	if err != nil || shortenedURL.Id != saved.Id {
+		t.Errorf("got %v, %v, want the saved shortened URL", shortenedURL, err)
+	}
+}
+
+func TestCachedShortenedURLWithFallback(t *testing.T) {
+	server, client := newTestValkey(t)
+	primary := &stubShortenedURLStore{bySlug: map[string]*model.ShortenedURL{"abc": {Id: 1, Slug: "abc", OriginalURL: "https://example.com/"}}}
+	replica := &stubShortenedURLStore{bySlug: map[string]*model.ShortenedURL{"abc": {Id: 1, Slug: "abc", OriginalURL: "https://example.com/stale"}}}
+	cache := NewCachedShortenedURLWithFallback(primary, replica, client, time.Hour, time.Minute)
+	ctx := context.Background()
+
+	server.SetError("ERR Valkey is unavailable")
+	shortenedURL, err := cache.FindBySlug(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shortenedURL.OriginalURL != "https://example.com/stale" || replica.lookups != 1 || primary.lookups != 0 {
+		t.Errorf("got %q with %d replica and %d primary lookups, want the replica to serve unavailable cache", shortenedURL.OriginalURL, replica.lookups, primary.lookups)
+	}
+
+	server.SetError("")
+	for range 2 {
+		if shortenedURL, err = cache.FindBySlug(ctx, "abc"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if shortenedURL.OriginalURL != "https://example.com/" || replica.lookups != 1 || primary.lookups != 1 {
+		t.Errorf("got %q with %d replica and %d primary lookups, want the miss loaded from the primary and cached", shortenedURL.OriginalURL, replica.lookups, primary.lookups)
+	}
+}
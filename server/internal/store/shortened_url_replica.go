@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"sync/atomic"
+)
+
+// shortenedURLReplicas reads the shortened URLs by ID and slug from the read replicas in turn, everything else
+// including the deduplication by URL hash goes to the primary.
+type shortenedURLReplicas struct {
+	ShortenedURL
+	replicas []ShortenedURL
+	next     atomic.Uint64
+}
+
+func (s *shortenedURLReplicas) Find(ctx context.Context, id int64) (*model.ShortenedURL, error) {
+	shortenedURL, err := s.replica().Find(ctx, id)
+	if err != nil {
+		// The replica may lag behind the primary or be unavailable, in which case the primary answers.
+		return s.ShortenedURL.Find(ctx, id)
+	}
+
+	return shortenedURL, nil
+}
+
+func (s *shortenedURLReplicas) FindBySlug(ctx context.Context, slug string) (*model.ShortenedURL, error) {
+	shortenedURL, err := s.replica().FindBySlug(ctx, slug)
+	if err != nil {
+		return s.ShortenedURL.FindBySlug(ctx, slug)
+	}
+
+	return shortenedURL, nil
+}
+
+func (s *shortenedURLReplicas) replica() ShortenedURL {
+	return s.replicas[s.next.Add(1)%uint64(len(s.replicas))]
+}
+
+// NewReplicatedShortenedURL routes the lookups by ID and slug to the replicas and the rest to the primary. The
+// primary is returned as is when there are no replicas.
+func NewReplicatedShortenedURL(primary ShortenedURL, replicas []ShortenedURL) ShortenedURL {
+	if len(replicas) == 0 {
+		return primary
+	}
+
+	return &shortenedURLReplicas{
+		ShortenedURL: primary,
+		replicas:     replicas,
+	}
+}
@@ -2,23 +2,136 @@ package store
 
 import (
 	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/valkey-io/valkey-go"
+	"strconv"
+	"sync"
 )
 
 type ShortenedURLSequence interface {
 	NextId(ctx context.Context) (int64, error)
+	// NextIds reserves n IDs, which are not necessarily consecutive.
+	NextIds(ctx context.Context, n int) ([]int64, error)
+	// Reconcile makes sure that the sequence never hands out IDs lower than or equal to the given one.
+	Reconcile(ctx context.Context, maxId int64) error
 }
 
 const shortenedURLSequenceKey = "ShortenedURLSequence"
 
+// raiseShortenedURLSequenceScript sets the sequence to the given value unless it's already greater.
+var raiseShortenedURLSequenceScript = valkey.NewLuaScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local target = tonumber(ARGV[1])
+if current < target then
+	redis.call('SET', KEYS[1], target)
+	return target
+end
+return current
+`)
+
+// shortenedURLSequenceValkey reserves blocks of IDs with single INCRBY and hands them out locally, so that
+// the most of the calls don't need a round trip to Valkey. The unused IDs of the reserved block are lost on restart.
 type shortenedURLSequenceValkey struct {
-	client valkey.Client
+	client    valkey.Client
+	blockSize int64
+
+	mu sync.Mutex
+	// next and last delimit the locally available IDs, the block is exhausted when next > last.
+	next int64
+	last int64
 }
 
 func (s *shortenedURLSequenceValkey) NextId(ctx context.Context) (int64, error) {
-	return s.client.Do(ctx, s.client.B().Incr().Key(shortenedURLSequenceKey).Build()).AsInt64()
+	ids, err := s.NextIds(ctx, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	return ids[0], nil
+}
+
+func (s *shortenedURLSequenceValkey) NextIds(ctx context.Context, n int) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		if s.next > s.last {
+			if err := s.reserve(ctx, max(s.blockSize, int64(n-len(ids)))); err != nil {
+				return nil, err
+			}
+		}
+		ids = append(ids, s.next)
+		s.next++
+	}
+
+	return ids, nil
+}
+
+func (s *shortenedURLSequenceValkey) reserve(ctx context.Context, size int64) error {
+	last, err := s.client.Do(ctx, s.client.B().Incrby().Key(shortenedURLSequenceKey).Increment(size).Build()).AsInt64()
+	if err != nil {
+		return err
+	}
+	s.next, s.last = last-size+1, last
+
+	return nil
+}
+
+func (s *shortenedURLSequenceValkey) Reconcile(ctx context.Context, maxId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := raiseShortenedURLSequenceScript.Exec(ctx, s.client, []string{shortenedURLSequenceKey}, []string{strconv.FormatInt(maxId, 10)}).Error()
+	if err != nil {
+		return err
+	}
+	// The IDs of the locally reserved block up to maxId may collide with the existing ones, so they're skipped. The
+	// block is exhausted and refilled from the raised sequence when all of them collide.
+	s.next = max(s.next, maxId+1)
+
+	return nil
+}
+
+// NewShortenedURLSequence creates Valkey backed sequence which reserves blockSize IDs at once.
+func NewShortenedURLSequence(client valkey.Client, blockSize int64) ShortenedURLSequence {
+	return &shortenedURLSequenceValkey{
+		client:    client,
+		blockSize: max(blockSize, 1),
+		next:      1,
+		last:      0,
+	}
+}
+
+// shortenedURLSequencePG hands out IDs from the url_map_id_seq Postgres sequence.
+type shortenedURLSequencePG struct {
+	db *pgxpool.Pool
+}
+
+func (s *shortenedURLSequencePG) NextId(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(ctx, "SELECT nextval('url_map_id_seq')").Scan(&id)
+
+	return id, err
+}
+
+func (s *shortenedURLSequencePG) NextIds(ctx context.Context, n int) ([]int64, error) {
+	rows, err := s.db.Query(ctx, "SELECT nextval('url_map_id_seq') FROM generate_series(1, $1)", n)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+func (s *shortenedURLSequencePG) Reconcile(ctx context.Context, maxId int64) error {
+	sql := "SELECT setval('url_map_id_seq', $1) FROM url_map_id_seq WHERE last_value < $1"
+	_, err := s.db.Exec(ctx, sql, maxId)
+
+	return err
 }
 
-func NewShortenedURLSequence(client valkey.Client) ShortenedURLSequence {
-	return &shortenedURLSequenceValkey{client: client}
+func NewShortenedURLSequencePG(db *pgxpool.Pool) ShortenedURLSequence {
+	return &shortenedURLSequencePG{db: db}
 }
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestShortenedURLSequenceRefillsBlocks(t *testing.T) {
+	server, client := newTestValkey(t)
+	sequence := NewShortenedURLSequence(client, 3)
+
+	var ids []int64
+	for range 5 {
+		id, err := sequence.NextId(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if want := []int64{1, 2, 3, 4, 5}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if got, _ := server.Get(shortenedURLSequenceKey); got != "6" {
+		t.Errorf("got reserved up to %s, want 6 after refilling the block by 3", got)
+	}
+}
+
+func TestShortenedURLSequenceConcurrentNextId(t *testing.T) {
+	_, client := newTestValkey(t)
+	// Two replicas share the sequence in Valkey, each with its own blocks.
+	sequences := []ShortenedURLSequence{NewShortenedURLSequence(client, 7), NewShortenedURLSequence(client, 7)}
+
+	var mu sync.Mutex
+	seen := map[int64]bool{}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				id, err := sequences[i%2].NextId(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("got id %d twice", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 400 {
+		t.Errorf("got %d distinct ids, want %d", len(seen), 400)
+	}
+}
+
+func TestShortenedURLSequenceReconcile(t *testing.T) {
+	tests := map[string]struct {
+		maxId int64
+		want  []int64
+	}{
+		"before the block":        {maxId: 0, want: []int64{2, 3}},
+		"overlapping the block":   {maxId: 5, want: []int64{6, 7}},
+		"covering the block":      {maxId: 10, want: []int64{11, 12}},
+		"beyond the reserved ids": {maxId: 25, want: []int64{26, 27}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, client := newTestValkey(t)
+			sequence := NewShortenedURLSequence(client, 10)
+			ctx := context.Background()
+			// Reserves the block of 1-10.
+			if _, err := sequence.NextId(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := sequence.Reconcile(ctx, tt.maxId); err != nil {
+				t.Fatal(err)
+			}
+
+			ids, err := sequence.NextIds(ctx, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("got %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
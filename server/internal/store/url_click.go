@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"net/url"
+)
+
+const unknownReferrer = "(direct)"
+
+type URLClick interface {
+	// Record adds the given click events to the daily aggregated counters.
+	Record(ctx context.Context, events []model.ClickEvent) error
+	Stats(ctx context.Context, slug string, days int) (*model.ClickStatsRes, error)
+}
+
+type urlClickPG struct {
+	db *pgxpool.Pool
+}
+
+type dailyClicksKey struct {
+	slug string
+	day  string
+}
+
+type referrerClicksKey struct {
+	dailyClicksKey
+	referrer string
+}
+
+func (s *urlClickPG) Record(ctx context.Context, events []model.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	daily := make(map[dailyClicksKey]int64)
+	referrers := make(map[referrerClicksKey]int64)
+	for _, event := range events {
+		key := dailyClicksKey{slug: event.Slug, day: event.OccurredAt.UTC().Format("2006-01-02")}
+		daily[key]++
+		referrers[referrerClicksKey{dailyClicksKey: key, referrer: referrerHost(event.Referrer)}]++
+	}
+
+	batch := &pgx.Batch{}
+	for key, clicks := range daily {
+		batch.Queue(`INSERT INTO url_clicks (slug, day, clicks) VALUES ($1, $2, $3)
+			ON CONFLICT (slug, day) DO UPDATE SET clicks = url_clicks.clicks + excluded.clicks`,
+			key.slug, key.day, clicks)
+	}
+	for key, clicks := range referrers {
+		batch.Queue(`INSERT INTO url_click_referrers (slug, day, referrer, clicks) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (slug, day, referrer) DO UPDATE SET clicks = url_click_referrers.clicks + excluded.clicks`,
+			key.slug, key.day, key.referrer, clicks)
+	}
+
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, batch).Close()
+	})
+}
+
+func (s *urlClickPG) Stats(ctx context.Context, slug string, days int) (*model.ClickStatsRes, error) {
+	stats := &model.ClickStatsRes{
+		Slug:      slug,
+		Daily:     []model.DailyClicks{},
+		Referrers: []model.ReferrerClicks{},
+	}
+
+	sql := "SELECT COALESCE(SUM(clicks), 0) FROM url_clicks WHERE slug = $1"
+	if err := s.db.QueryRow(ctx, sql, slug).Scan(&stats.TotalClicks); err != nil {
+		return nil, err
+	}
+
+	sql = `SELECT to_char(day, 'YYYY-MM-DD'), clicks FROM url_clicks
+		WHERE slug = $1 AND day > CURRENT_DATE - $2::INT ORDER BY day`
+	rows, err := s.db.Query(ctx, sql, slug, days)
+	if err != nil {
+		return nil, err
+	}
+	stats.Daily, err = pgx.AppendRows(stats.Daily, rows, pgx.RowToStructByPos[model.DailyClicks])
+	if err != nil {
+		return nil, err
+	}
+
+	sql = `SELECT referrer, SUM(clicks) AS clicks FROM url_click_referrers
+		WHERE slug = $1 AND day > CURRENT_DATE - $2::INT GROUP BY referrer ORDER BY clicks DESC LIMIT 10`
+	rows, err = s.db.Query(ctx, sql, slug, days)
+	if err != nil {
+		return nil, err
+	}
+	stats.Referrers, err = pgx.AppendRows(stats.Referrers, rows, pgx.RowToStructByPos[model.ReferrerClicks])
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// referrerHost reduces the referrer to its host to keep the number of aggregated rows bounded.
+func referrerHost(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return unknownReferrer
+	}
+
+	return u.Hostname()
+}
+
+func NewURLClick(db *pgxpool.Pool) URLClick {
+	return &urlClickPG{db: db}
+}
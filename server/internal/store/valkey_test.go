@@ -0,0 +1,20 @@
+package store
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/valkey-io/valkey-go"
+	"testing"
+)
+
+// newTestValkey starts in-memory Valkey for the duration of the test. The client-side cache is disabled, as it relies
+// on the server-assisted invalidation.
+func newTestValkey(t *testing.T) (*miniredis.Miniredis, valkey.Client) {
+	server := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{server.Addr()}, DisableCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	return server, client
+}
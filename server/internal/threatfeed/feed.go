@@ -0,0 +1,145 @@
+package threatfeed
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Entry is a single malicious URL reported by a feed.
+type Entry struct {
+	URL    string
+	Threat string
+	Tags   []string
+}
+
+// Feed is a source of malicious URLs e.g. URLhaus, OpenPhish or a local file.
+type Feed interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Entry, error)
+}
+
+// ErrNotModified is returned by conditional feeds which haven't changed since the given version.
+var ErrNotModified = errors.New("the feed has not been modified")
+
+// ConditionalFeed is a feed which can skip the download when it hasn't changed since the version fetched last time.
+type ConditionalFeed interface {
+	Feed
+	// FetchIfModified fetches the entries unless the feed is still of the given version, in which case ErrNotModified
+	// is returned. The version is opaque and empty version fetches the feed unconditionally.
+	FetchIfModified(ctx context.Context, version string) ([]Entry, string, error)
+}
+
+var errURLColumnNotFound = errors.New("the CSV header doesn't contain url column")
+
+// parseText parses feeds listing single URL per line. Empty lines and lines starting with # are skipped.
+func parseText(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, Entry{URL: line})
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseCSV parses PhishTank-style CSV dumps whose header names the column holding URLs "url".
+func parseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	urlColumn, threatColumn, tagsColumn := -1, -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "url":
+			urlColumn = i
+		case "threat":
+			threatColumn = i
+		case "tags":
+			tagsColumn = i
+		}
+	}
+	if urlColumn < 0 {
+		return nil, errURLColumnNotFound
+	}
+
+	var entries []Entry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if urlColumn >= len(record) || strings.TrimSpace(record[urlColumn]) == "" {
+			continue
+		}
+
+		entry := Entry{URL: strings.TrimSpace(record[urlColumn])}
+		if threatColumn >= 0 && threatColumn < len(record) {
+			entry.Threat = record[threatColumn]
+		}
+		if tagsColumn >= 0 && tagsColumn < len(record) && record[tagsColumn] != "" {
+			entry.Tags = strings.Split(record[tagsColumn], ",")
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// httpFeed downloads and parses a feed published over HTTP.
+type httpFeed struct {
+	name       string
+	endpoint   string
+	httpClient *http.Client
+	parse      func(io.Reader) ([]Entry, error)
+}
+
+func (f *httpFeed) Name() string {
+	return f.name
+}
+
+func (f *httpFeed) Fetch(ctx context.Context) ([]Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s feed: %v", f.name, res.StatusCode)
+	}
+
+	return f.parse(res.Body)
+}
+
+// NewTextFeed creates feed of URLs listed one per line e.g. OpenPhish.
+func NewTextFeed(name string, endpoint string, httpClient *http.Client) Feed {
+	return &httpFeed{name: name, endpoint: endpoint, httpClient: httpClient, parse: parseText}
+}
+
+// NewCSVFeed creates feed of CSV dumps e.g. PhishTank.
+func NewCSVFeed(name string, endpoint string, httpClient *http.Client) Feed {
+	return &httpFeed{name: name, endpoint: endpoint, httpClient: httpClient, parse: parseCSV}
+}
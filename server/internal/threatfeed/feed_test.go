@@ -0,0 +1,108 @@
+package threatfeed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	entries, err := parseText(strings.NewReader("# OpenPhish feed\nhttps://phish.example.com/login\n\n  http://198.51.100.7/bin.sh  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"https://phish.example.com/login", "http://198.51.100.7/bin.sh"}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.URL != want[i] {
+			t.Errorf("got %q, want %q", entry.URL, want[i])
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	dump := "phish_id,url,phish_detail_url,submission_time,verified\n" +
+		"8512345,https://phish.example.com/login,http://www.phishtank.com/phish_detail.php?phish_id=8512345,2025-01-01T00:00:00+00:00,yes\n" +
+		"8512346,\"https://phish.example.net/a,b\",http://www.phishtank.com/phish_detail.php?phish_id=8512346,2025-01-01T00:00:00+00:00,yes\n"
+	entries, err := parseCSV(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"https://phish.example.com/login", "https://phish.example.net/a,b"}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.URL != want[i] {
+			t.Errorf("got %q, want %q", entry.URL, want[i])
+		}
+	}
+
+	if _, err = parseCSV(strings.NewReader("phish_id,link\n1,https://phish.example.com\n")); err == nil {
+		t.Errorf("got no error for CSV without url column")
+	}
+}
+
+func TestHTTPFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("https://phish.example.com/login\n"))
+	}))
+	defer server.Close()
+
+	entries, err := NewTextFeed("openphish", server.URL, server.Client()).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].URL != "https://phish.example.com/login" {
+		t.Errorf("got %v, want single entry", entries)
+	}
+}
+
+func TestFileFeedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	snapshot, err := os.Create(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteSnapshot(snapshot, []Entry{{URL: "http://198.51.100.7/bin.sh", Threat: "malware_download", Tags: []string{"mirai"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = snapshot.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"openphish.txt": "https://phish.example.com/login\n",
+		"phishtank.csv": "phish_id,url\n1,https://phish.example.net/login\n",
+		".snapshot.swp": "https://ignored.example.com\n",
+	}
+	for name, content := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := NewFileFeed("local", dir).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := map[string]Entry{}
+	for _, entry := range entries {
+		urls[entry.URL] = entry
+	}
+	if len(urls) != 3 {
+		t.Errorf("got %v, want entries of the 3 visible files", entries)
+	}
+	if entry := urls["http://198.51.100.7/bin.sh"]; entry.Threat != "malware_download" || len(entry.Tags) != 1 {
+		t.Errorf("got %+v, want the threat and tags of the snapshot", entry)
+	}
+}
@@ -0,0 +1,77 @@
+package threatfeed
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileFeed reads a feed from local file or from all the files of local directory, so that the guardian can work
+// without access to the online feeds. Files with .json or .zip extension are parsed in the format of URLhaus, with
+// .csv extension as CSV and any other as plain text.
+type fileFeed struct {
+	name string
+	path string
+}
+
+func (f *fileFeed) Name() string {
+	return f.name
+}
+
+func (f *fileFeed) Fetch(_ context.Context) ([]Entry, error) {
+	path := filepath.Clean(f.path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return parseFile(path)
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, dirEntry := range dirEntries {
+		// Hidden files are skipped, as they are typically leftovers of editors or of copying.
+		if dirEntry.IsDir() || strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		fileEntries, err := parseFile(filepath.Join(path, dirEntry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	return entries, nil
+}
+
+func parseFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return fileParser(path)(file)
+}
+
+func fileParser(path string) func(io.Reader) ([]Entry, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".zip":
+		return parseURLhaus
+	case ".csv":
+		return parseCSV
+	default:
+		return parseText
+	}
+}
+
+// NewFileFeed creates feed read from the given file or directory.
+func NewFileFeed(name string, path string) Feed {
+	return &fileFeed{name: name, path: path}
+}
@@ -0,0 +1,83 @@
+package threatfeed
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/urlhaus"
+	"io"
+	"strings"
+)
+
+type urlhausFeed struct {
+	client urlhaus.Client
+}
+
+func (f *urlhausFeed) Name() string {
+	return "urlhaus"
+}
+
+func (f *urlhausFeed) Fetch(ctx context.Context) ([]Entry, error) {
+	entries, _, err := f.FetchIfModified(ctx, "")
+
+	return entries, err
+}
+
+func (f *urlhausFeed) FetchIfModified(ctx context.Context, version string) ([]Entry, string, error) {
+	urls, validators, err := f.client.FetchIfModified(ctx, decodeValidators(version))
+	if errors.Is(err, urlhaus.ErrNotModified) {
+		return nil, version, ErrNotModified
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return urlhausEntries(urls), encodeValidators(validators), nil
+}
+
+// parseURLhaus parses feeds in the JSON format of URLhaus, either plain or zipped.
+func parseURLhaus(r io.Reader) ([]Entry, error) {
+	urls, err := urlhaus.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return urlhausEntries(urls), nil
+}
+
+// WriteSnapshot writes the entries in the JSON format of URLhaus, which can be read back by the file feed.
+func WriteSnapshot(w io.Writer, entries []Entry) error {
+	urls := make([]urlhaus.MaliciousURL, 0, len(entries))
+	for _, entry := range entries {
+		urls = append(urls, urlhaus.MaliciousURL{URL: entry.URL, Threat: entry.Threat, Tags: entry.Tags})
+	}
+
+	return urlhaus.Encode(w, urls)
+}
+
+func urlhausEntries(urls []urlhaus.MaliciousURL) []Entry {
+	entries := make([]Entry, 0, len(urls))
+	for _, url := range urls {
+		entries = append(entries, Entry{URL: url.URL, Threat: url.Threat, Tags: url.Tags})
+	}
+
+	return entries
+}
+
+// encodeValidators encodes the validators as version, neither ETag nor HTTP date contain new lines.
+func encodeValidators(validators urlhaus.Validators) string {
+	if validators == (urlhaus.Validators{}) {
+		return ""
+	}
+
+	return validators.ETag + "\n" + validators.LastModified
+}
+
+func decodeValidators(version string) urlhaus.Validators {
+	etag, lastModified, _ := strings.Cut(version, "\n")
+
+	return urlhaus.Validators{ETag: etag, LastModified: lastModified}
+}
+
+func NewURLhausFeed(client urlhaus.Client) ConditionalFeed {
+	return &urlhausFeed{client: client}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Middleware starts server span for every request, continuing the trace of the W3C trace context headers. The span
+// is named by the chi route pattern once the request has been routed and is tagged with the request ID.
+func Middleware(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if requestId := middleware.GetReqID(r.Context()); requestId != "" {
+			span.SetAttributes(requestIdKey.String(requestId))
+		}
+		next.ServeHTTP(w, r)
+
+		if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil && routeCtx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + routeCtx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(routeCtx.RoutePattern()))
+		}
+	})
+
+	return otelhttp.NewHandler(named, "http.request", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method
+	}))
+}
+
+// StartHandler starts span of the given handler, which ends with the status of the response when end is called. Only
+// the server errors fail the span, the client errors are regular outcomes of the handlers.
+func StartHandler(w http.ResponseWriter, r *http.Request, name string) (context.Context, http.ResponseWriter, func()) {
+	ctx, span := tracer.Start(r.Context(), name)
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+	return ctx, ww, func() {
+		status := ww.Status()
+		if status == 0 {
+			// Nothing has been written, which net/http responds to with 200.
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}
+}
+
+// NewTransport wraps the transport of outgoing requests with client spans propagating the trace context.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// spanRecorder records the spans of all the tests, as the tracer is bound to the first global provider installed.
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	return recorder
+})
+
+func TestMiddleware(t *testing.T) {
+	recorder := spanRecorder()
+	ended := len(recorder.Ended())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, Middleware)
+	r.Get("/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		_, end := Start(r.Context(), "handler.Resolve")
+		end(nil)
+		w.WriteHeader(http.StatusFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()[ended:]
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want %d", len(spans), 2)
+	}
+	handlerSpan, serverSpan := spans[0], spans[1]
+	if serverSpan.Name() != "GET /{slug}" {
+		t.Errorf("got server span %q, want %q", serverSpan.Name(), "GET /{slug}")
+	}
+	if traceId := serverSpan.SpanContext().TraceID().String(); traceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("got trace %s, want the propagated one", traceId)
+	}
+	if handlerSpan.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Errorf("got handler span with parent %s, want %s", handlerSpan.Parent().SpanID(), serverSpan.SpanContext().SpanID())
+	}
+}
+
+func TestStartHandler(t *testing.T) {
+	recorder := spanRecorder()
+
+	tests := map[string]struct {
+		status     int
+		wantStatus int
+		wantCode   codes.Code
+	}{
+		"redirect":     {status: http.StatusFound, wantStatus: http.StatusFound, wantCode: codes.Unset},
+		"nothing":      {wantStatus: http.StatusOK, wantCode: codes.Unset},
+		"client error": {status: http.StatusNotFound, wantStatus: http.StatusNotFound, wantCode: codes.Unset},
+		"server error": {status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantCode: codes.Error},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				_, w, end := StartHandler(w, r, "handler.Resolve")
+				defer end()
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+			}
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abcd", nil))
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			if span.Status().Code != tt.wantCode {
+				t.Errorf("got status %v, want %v", span.Status().Code, tt.wantCode)
+			}
+			want := semconv.HTTPResponseStatusCode(tt.wantStatus)
+			if !slices.Contains(span.Attributes(), want) {
+				t.Errorf("got attributes %v, want %v", span.Attributes(), want)
+			}
+		})
+	}
+}
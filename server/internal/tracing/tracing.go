@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+)
+
+// instrumentationName names the tracer of all the spans created by snip.
+const instrumentationName = "github.com/aboyadzhiev/snip/server"
+
+// The exporters of the spans.
+const (
+	ExporterNone    = "none"
+	ExporterOTLP    = "otlp"
+	ExporterConsole = "console"
+	ExporterFile    = "file"
+)
+
+// tracer is resolved through the global provider, so that the spans are exported once Init has been called.
+var tracer = otel.Tracer(instrumentationName)
+
+type Config struct {
+	// Exporter is one of the exporters, the OTLP exporter is configured by the standard OTEL_EXPORTER_OTLP_*
+	// variables.
+	Exporter string
+	// File is the file the spans are appended to by the file exporter.
+	File string
+}
+
+// Init installs the global tracer provider and the W3C trace context propagator. The returned function flushes the
+// spans and has to be called before exiting.
+func Init(ctx context.Context, config Config, stdout io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterConsole:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+	case ExporterFile:
+		if file, err = os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q: must be one of none, otlp, console or file", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The service name and the resource attributes can be overridden by OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName("snip")),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sampler is configured by the standard OTEL_TRACES_SAMPLER variables.
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start starts span of the given name, which ends with the error when end is called.
+func Start(ctx context.Context, name string, attributes ...trace.SpanStartOption) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, name, attributes...)
+
+	return ctx, func(err error) {
+		End(span, err)
+	}
+}
+
+// End records the error, if any, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
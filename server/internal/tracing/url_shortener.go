@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/aboyadzhiev/snip/server/internal/model"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// urlShortener starts span for every call of the shortener.
+type urlShortener struct {
+	service.URLShortener
+}
+
+func (s *urlShortener) Shorten(ctx context.Context, shortenURLReq model.ShortenURLReq) (shortenURL string, err error) {
+	ctx, end := Start(ctx, "urlShortener.Shorten")
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.Shorten(ctx, shortenURLReq)
+}
+
+func (s *urlShortener) ShortenBatch(ctx context.Context, shortenURLReqs []model.ShortenURLReq) (results []model.ShortenResult, err error) {
+	ctx, end := Start(ctx, "urlShortener.ShortenBatch", trace.WithAttributes(attribute.Int("snip.batch.size", len(shortenURLReqs))))
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.ShortenBatch(ctx, shortenURLReqs)
+}
+
+func (s *urlShortener) Resolve(ctx context.Context, slug string) (url string, err error) {
+	ctx, end := Start(ctx, "urlShortener.Resolve", trace.WithAttributes(attribute.String("snip.slug", slug)))
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.Resolve(ctx, slug)
+}
+
+func (s *urlShortener) List(ctx context.Context, ownerId string, listReq model.ListShortenedURLsReq) (listRes *model.ShortenedURLListRes, err error) {
+	ctx, end := Start(ctx, "urlShortener.List")
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.List(ctx, ownerId, listReq)
+}
+
+func (s *urlShortener) Get(ctx context.Context, ownerId string, slug string) (shortenedURLRes *model.ShortenedURLRes, err error) {
+	ctx, end := Start(ctx, "urlShortener.Get", trace.WithAttributes(attribute.String("snip.slug", slug)))
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.Get(ctx, ownerId, slug)
+}
+
+func (s *urlShortener) Update(ctx context.Context, ownerId string, slug string, updateReq model.UpdateShortenedURLReq) (shortenedURLRes *model.ShortenedURLRes, err error) {
+	ctx, end := Start(ctx, "urlShortener.Update", trace.WithAttributes(attribute.String("snip.slug", slug)))
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.Update(ctx, ownerId, slug, updateReq)
+}
+
+func (s *urlShortener) Delete(ctx context.Context, ownerId string, slug string) (err error) {
+	ctx, end := Start(ctx, "urlShortener.Delete", trace.WithAttributes(attribute.String("snip.slug", slug)))
+	defer func() { end(failure(err)) }()
+
+	return s.URLShortener.Delete(ctx, ownerId, slug)
+}
+
+// failure returns the error unless it's an expected outcome of the call, which must not fail the span.
+func failure(err error) error {
+	for _, expected := range []error{
+		service.ErrMaliciousURLDetected,
+		service.ErrDestinationNotAllowed,
+		service.ErrIllegalSlug,
+		service.ErrSlugAlreadyTaken,
+		store.ErrShortenedURLNotFound,
+		service.ErrShortenedURLExpired,
+		service.ErrShortenedURLDeleted,
+		service.ErrShortenedURLQuarantined,
+	} {
+		if errors.Is(err, expected) {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// NewURLShortener decorates the shortener with the spans of its calls.
+func NewURLShortener(shortener service.URLShortener) service.URLShortener {
+	return &urlShortener{URLShortener: shortener}
+}
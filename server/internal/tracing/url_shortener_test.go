@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aboyadzhiev/snip/server/internal/service"
+	"github.com/aboyadzhiev/snip/server/internal/store"
+	"go.opentelemetry.io/otel/codes"
+	"testing"
+)
+
+// stubURLShortener fails every resolution with the given error.
+type stubURLShortener struct {
+	service.URLShortener
+	err error
+}
+
+func (s *stubURLShortener) Resolve(context.Context, string) (string, error) {
+	return "", s.err
+}
+
+func TestURLShortener(t *testing.T) {
+	recorder := spanRecorder()
+
+	tests := map[string]struct {
+		err      error
+		wantCode codes.Code
+	}{
+		"resolved":    {wantCode: codes.Unset},
+		"not found":   {err: store.ErrShortenedURLNotFound, wantCode: codes.Unset},
+		"quarantined": {err: fmt.Errorf("abcd: %w", service.ErrShortenedURLQuarantined), wantCode: codes.Unset},
+		"failed":      {err: errors.New("connection refused"), wantCode: codes.Error},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			shortener := NewURLShortener(&stubURLShortener{err: tt.err})
+			if _, err := shortener.Resolve(context.Background(), "abcd"); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			if span.Status().Code != tt.wantCode {
+				t.Errorf("got status %v, want %v", span.Status().Code, tt.wantCode)
+			}
+			if recorded := len(span.Events()) > 0; recorded != (tt.wantCode == codes.Error) {
+				t.Errorf("got error recorded %v, want %v", recorded, tt.wantCode == codes.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"github.com/valkey-io/valkey-go"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+// valkeyClient starts client span for every command and pipeline. The dedicated connections aren't traced.
+type valkeyClient struct {
+	valkey.Client
+}
+
+func (c *valkeyClient) Do(ctx context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+	ctx, span := c.start(ctx, cmd.Commands(), 1)
+	res := c.Client.Do(ctx, cmd)
+	End(span, valkeyErr(res))
+
+	return res
+}
+
+func (c *valkeyClient) DoMulti(ctx context.Context, multi ...valkey.Completed) []valkey.ValkeyResult {
+	if len(multi) == 0 {
+		return c.Client.DoMulti(ctx, multi...)
+	}
+	ctx, span := c.start(ctx, multi[0].Commands(), len(multi))
+	results := c.Client.DoMulti(ctx, multi...)
+	var err error
+	for _, res := range results {
+		if err = valkeyErr(res); err != nil {
+			break
+		}
+	}
+	End(span, err)
+
+	return results
+}
+
+func (c *valkeyClient) DoCache(ctx context.Context, cmd valkey.Cacheable, ttl time.Duration) valkey.ValkeyResult {
+	ctx, span := c.start(ctx, cmd.Commands(), 1)
+	res := c.Client.DoCache(ctx, cmd, ttl)
+	End(span, valkeyErr(res))
+
+	return res
+}
+
+// start starts the span named by the first command, the arguments aren't recorded as they may hold the URLs.
+func (c *valkeyClient) start(ctx context.Context, commands []string, count int) (context.Context, trace.Span) {
+	command := "UNKNOWN"
+	if len(commands) > 0 {
+		command = strings.ToUpper(commands[0])
+	}
+
+	return tracer.Start(ctx, "valkey "+command, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemRedis,
+		semconv.DBOperationName(command),
+		attribute.Int("db.operation.batch.size", count),
+	))
+}
+
+// valkeyErr returns the error of the result, nil replies aren't errors of the call.
+func valkeyErr(res valkey.ValkeyResult) error {
+	if err := res.Error(); err != nil && !valkey.IsValkeyNil(err) {
+		return err
+	}
+
+	return nil
+}
+
+// NewValkeyClient decorates the client with the spans of the commands.
+func NewValkeyClient(client valkey.Client) valkey.Client {
+	return &valkeyClient{Client: client}
+}
@@ -1,15 +1,30 @@
 package urlhaus
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 )
 
+var ErrNotModified = errors.New("the feed has not been modified")
+
 type MaliciousURL struct {
+	// Id is the URLhaus ID of the reported URL, which is the key of the entries in the feed.
+	Id          string   `json:"-"`
 	DateAdded   string   `json:"dateadded"`
 	URL         string   `json:"url"`
 	URLStatus   string   `json:"url_status"`
@@ -20,10 +35,24 @@ type MaliciousURL struct {
 	Reporter    string   `json:"reporter"`
 }
 
+// Validators identify the version of the feed for the conditional requests.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
 type Client interface {
 	FetchAll(ctx context.Context) ([]MaliciousURL, error)
+	// FetchIfModified fetches the feed unless its version matches the given validators, in which case ErrNotModified
+	// is returned. The validators of the fetched version are returned along with the URLs.
+	FetchIfModified(ctx context.Context, validators Validators) ([]MaliciousURL, Validators, error)
 }
 
+var tracer = otel.Tracer("github.com/aboyadzhiev/snip/server/internal/urlhaus")
+
+// zipMagic starts every zip archive, the zipped feed is recognized by it regardless of the content type.
+var zipMagic = []byte("PK\x03\x04")
+
 type urlhausClient struct {
 	apiEndpoint string
 	httpClient  *http.Client
@@ -31,45 +60,183 @@ type urlhausClient struct {
 }
 
 func (c *urlhausClient) FetchAll(ctx context.Context) ([]MaliciousURL, error) {
-	req, err := http.NewRequest(http.MethodGet, c.apiEndpoint, nil)
+	urls, _, err := c.FetchIfModified(ctx, Validators{})
+
+	return urls, err
+}
+
+func (c *urlhausClient) FetchIfModified(ctx context.Context, validators Validators) (urls []MaliciousURL, _ Validators, err error) {
+	// The span covers the decoding as well, which takes most of the time of large feeds.
+	ctx, span := tracer.Start(ctx, "urlhaus.FetchIfModified")
+	defer func() {
+		span.SetAttributes(attribute.Int("urlhaus.urls", len(urls)), attribute.Bool("urlhaus.not_modified", errors.Is(err, ErrNotModified)))
+		if err != nil && !errors.Is(err, ErrNotModified) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiEndpoint, nil)
 	if err != nil {
 		c.logger.ErrorContext(ctx, fmt.Sprintf("Error creating request: %v", err))
-		return nil, err
+		return nil, Validators{}, err
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
 	}
 
 	res, err := c.httpClient.Do(req)
 	if err != nil {
 		c.logger.ErrorContext(ctx, fmt.Sprintf("Error executing request: %v", err))
-		return nil, err
+		return nil, Validators{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		c.logger.InfoContext(ctx, "The malicious urls have not been modified")
+		return nil, validators, ErrNotModified
 	}
 	if res.StatusCode != http.StatusOK {
-		c.logger.ErrorContext(ctx, fmt.Sprintf("Error fetching users: %v", res.StatusCode))
-		return nil, fmt.Errorf("error fetching users: %v", res.StatusCode)
+		c.logger.ErrorContext(ctx, fmt.Sprintf("Error fetching malicious urls: %v", res.StatusCode))
+		return nil, Validators{}, fmt.Errorf("error fetching malicious urls: %v", res.StatusCode)
 	}
 
-	defer res.Body.Close()
+	urls, err = Decode(res.Body)
+	if err != nil {
+		c.logger.ErrorContext(ctx, fmt.Sprintf("Error decoding body: %v", err))
+		return nil, Validators{}, err
+	}
 
-	body, err := io.ReadAll(res.Body)
+	c.logger.InfoContext(ctx, fmt.Sprintf("Fetched %d malicious urls", len(urls)))
+
+	return urls, Validators{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}, nil
+}
+
+// Decode decodes the feed in either JSON or zipped JSON format.
+func Decode(r io.Reader) ([]MaliciousURL, error) {
+	body := bufio.NewReader(r)
+	if magic, _ := body.Peek(len(zipMagic)); bytes.Equal(magic, zipMagic) {
+		return decodeZip(body)
+	}
+
+	return decode(body)
+}
+
+// Encode writes the URLs in the JSON format of the feed, the URLs without ID are given sequential IDs.
+func Encode(w io.Writer, urls []MaliciousURL) error {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	if _, err := buffered.WriteString("{"); err != nil {
+		return err
+	}
+	for i, url := range urls {
+		id := url.Id
+		if id == "" {
+			id = strconv.Itoa(i + 1)
+		}
+		if i > 0 {
+			if _, err := buffered.WriteString(","); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(id); err != nil {
+			return err
+		}
+		if _, err := buffered.WriteString(":"); err != nil {
+			return err
+		}
+		if err := encoder.Encode([]MaliciousURL{url}); err != nil {
+			return err
+		}
+	}
+	if _, err := buffered.WriteString("}\n"); err != nil {
+		return err
+	}
+
+	return buffered.Flush()
+}
+
+// decode streams the feed, which maps the IDs to the arrays of their entries, so that the body is never held in
+// memory as a whole.
+func decode(r io.Reader) ([]MaliciousURL, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	var urls []MaliciousURL
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		id, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v instead of id", token)
+		}
+
+		var entries []MaliciousURL
+		if err = decoder.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("entries of %s: %w", id, err)
+		}
+		for _, entry := range entries {
+			entry.Id = id
+			urls = append(urls, entry)
+		}
+	}
+
+	return urls, expectDelim(decoder, '}')
+}
+
+// decodeZip decodes the JSON file of the zipped feed. The archive has to be stored in temporary file first, as its
+// directory is at its end.
+func decodeZip(r io.Reader) ([]MaliciousURL, error) {
+	file, err := os.CreateTemp("", "urlhaus-*.zip")
 	if err != nil {
-		c.logger.ErrorContext(ctx, fmt.Sprintf("Error reading body: %v", err))
 		return nil, err
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	var rawURLs map[string][]MaliciousURL
-	err = json.Unmarshal(body, &rawURLs)
+	size, err := io.Copy(file, r)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(file, size)
 	if err != nil {
-		c.logger.ErrorContext(ctx, fmt.Sprintf("Error unmarshalling body: %v", err))
 		return nil, err
 	}
 
-	urls := make([]MaliciousURL, 0, len(rawURLs))
-	for _, u := range rawURLs {
-		urls = append(urls, u[0])
+	for _, f := range archive.File {
+		if !strings.EqualFold(path.Ext(f.Name), ".json") {
+			continue
+		}
+		content, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer content.Close()
+
+		return decode(content)
 	}
 
-	c.logger.InfoContext(ctx, fmt.Sprintf("Fetched %d malicious urls", len(urls)))
+	return nil, errors.New("the zipped feed contains no json file")
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected token %v instead of %v", token, delim)
+	}
 
-	return urls, nil
+	return nil
 }
 
 func NewClient(apiEndpoint string, httpClient *http.Client, logger *slog.Logger) Client {
@@ -0,0 +1,89 @@
+package urlhaus
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const feed = `{
+	"3384215": [
+		{"dateadded": "2025-02-01 10:00:00 UTC", "url": "http://198.51.100.7/bin.sh", "url_status": "online", "threat": "malware_download", "tags": ["elf", "mirai"]},
+		{"dateadded": "2025-02-02 10:00:00 UTC", "url": "http://198.51.100.7/bin.sh", "url_status": "online", "threat": "malware_download", "tags": ["32-bit"]}
+	],
+	"3384216": [
+		{"dateadded": "2025-02-01 11:00:00 UTC", "url": "https://phish.example.com/login", "url_status": "online", "threat": "malware_download", "tags": null}
+	]
+}`
+
+func TestFetchIfModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, feed)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, srv.Client(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	urls, validators, err := client.FetchIfModified(context.Background(), Validators{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 3 {
+		t.Fatalf("got %d urls, want %d", len(urls), 3)
+	}
+	ids := map[string]int{}
+	for _, url := range urls {
+		ids[url.Id]++
+	}
+	if ids["3384215"] != 2 || ids["3384216"] != 1 {
+		t.Errorf("got %v entries per id, want 2 entries of 3384215 and 1 of 3384216", ids)
+	}
+	if validators.ETag != `"v1"` {
+		t.Errorf("got ETag %q, want %q", validators.ETag, `"v1"`)
+	}
+
+	_, _, err = client.FetchIfModified(context.Background(), validators)
+	if !errors.Is(err, ErrNotModified) {
+		t.Errorf("got %v, want %v", err, ErrNotModified)
+	}
+}
+
+func TestFetchAllZipped(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, err := writer.Create("urlhaus_full.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.WriteString(file, feed); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		_, _ = w.Write(archive.Bytes())
+	}))
+	defer srv.Close()
+
+	urls, err := NewClient(srv.URL, srv.Client(), slog.New(slog.NewTextHandler(io.Discard, nil))).FetchAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 3 {
+		t.Errorf("got %d urls, want %d", len(urls), 3)
+	}
+}